@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"yt-api/internal/balance"
+	"yt-api/internal/model"
+
+	_ "github.com/joho/godotenv/autoload"
+)
+
+// ledgercheck 檢查鑰匙帳本的分錄加總與 key_balances 是否一致，不一致時以非零狀態結束
+func main() {
+	reconcile := flag.Bool("reconcile", false, "檢查前先補上尚未記帳的購買與出貨")
+	flag.Parse()
+
+	model.InitDB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if *reconcile {
+		n, err := balance.Reconcile(ctx, "")
+		if err != nil {
+			log.Fatal("Reconcile failed: ", err)
+		}
+		log.Printf("Posted %d missing ledger entries", n)
+	}
+
+	report, err := balance.Check(ctx)
+	if err != nil {
+		log.Fatal("Check failed: ", err)
+	}
+	log.Printf("Accounts: %d, ledger total: %d", report.Accounts, report.Total)
+	for _, m := range report.Mismatches {
+		log.Printf("Mismatch %s: ledger=%d materialized=%d", m.Account, m.Ledger, m.Materialized)
+	}
+	for _, tx := range report.UnbalancedTx {
+		log.Printf("Unbalanced transaction %s", tx.Hex())
+	}
+	if !report.OK() {
+		os.Exit(1)
+	}
+	log.Println("Ledger is consistent")
+}
@@ -1,10 +1,22 @@
 package main
 
 import (
+	"context"
+	"log"
 	"os"
+	"time"
+
+	"yt-api/internal/balance"
+	"yt-api/internal/bot"
+	"yt-api/internal/deliveries"
+	"yt-api/internal/dispatch"
 	. "yt-api/internal/handlers"
+	"yt-api/internal/inventory"
 	. "yt-api/internal/middleware"
+	"yt-api/internal/migrations"
 	"yt-api/internal/model"
+	"yt-api/internal/pricing"
+	"yt-api/internal/stock"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -12,10 +24,41 @@ import (
 )
 
 func main() {
-	// 初始化 Redis 連接
+	// 初始化 MongoDB 與 Redis 連接
+	model.InitDB()
 	model.InitRedis()
 	defer model.CloseRedis()
 
+	// 有尚未套用的 schema migration 時不啟動，需先執行 go run ./cmd/migrate up
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err := migrations.CheckPending(ctx)
+	cancel()
+	if err != nil {
+		log.Fatal("Refusing to start: ", err)
+	}
+
+	// 切換期間持續將舊的出貨紀錄同步到 deliveries，同步時扣帳，並補上尚未記帳的購買與出貨
+	deliveries.OnTraded = balance.DebitDelivery
+	deliveries.StartSync()
+	balance.StartReconcile()
+
+	// 監控出貨佇列與機器人心跳，逾時未確認的工作重新排入
+	bot.OnStockChanged = InvalidateStatusCache
+	dispatch.StartMonitor()
+	bot.StartMonitor()
+	inventory.StartSync()
+
+	// 釋放過期訂單預留的庫存
+	stock.OnReleased = InvalidateStatusCache
+	stock.StartReleaser()
+
+	// 定期更新用戶列表排序用的統計與暱稱
+	StartUserStatsRefresher()
+
+	// 啟動自動定價
+	pricing.OnPriceChanged = InvalidateStatusCache
+	pricing.StartScheduler()
+
 	port := "8080"
 
 	if os.Getenv("PORT") != "" {
@@ -30,15 +73,49 @@ func main() {
 	router.Use(cors.New(config))
 
 	router.GET("/api/v1/bot/status", GetPriceHandler)
+	router.POST("/api/v1/bot/deliveries/:id/trade", BotMiddleware, PostBotTradeHandler)
+	router.POST("/api/v1/bot/heartbeat", BotMiddleware, PostBotHeartbeatHandler)
+	router.GET("/api/v1/products", GetProductsHandler)
 	router.GET("/auth", AuthHandler)
 	router.GET("/api/v1/orders", AuthMiddleware, GetOrderHandler)
 	router.GET("/api/v2/orders", AuthMiddleware, GetOrderV2Handler)
 	router.GET("/api/v2/orders/:id", AuthMiddleware, GetOrderV2ByIDHandler)
+	router.POST("/api/v2/me/orders", AuthMiddleware, CreateOrderV2Handler)
+	router.GET("/api/v2/me/orders", AuthMiddleware, GetMyOrdersHandler)
+	router.GET("/api/v2/me/orders/:id", AuthMiddleware, GetMyOrderHandler)
+	router.POST("/api/v2/me/orders/:id/cancel", AuthMiddleware, CancelMyOrderHandler)
+	router.GET("/api/v2/me/balance", AuthMiddleware, GetMyBalanceHandler)
+	router.POST("/api/v2/me/deliveries", AuthMiddleware, CreateMyDeliveryHandler)
+	router.GET("/api/v2/me/deliveries", AuthMiddleware, GetMyDeliveriesHandler)
+	router.GET("/api/v2/me/deliveries/:id", AuthMiddleware, GetMyDeliveryHandler)
+	router.GET("/api/v2/admin/orders/held", AuthMiddleware, GetHeldOrdersHandler)
+	router.POST("/api/v2/admin/orders/:id/approve", AuthMiddleware, ApproveHeldOrderHandler)
+	router.POST("/api/v2/admin/orders/:id/reject", AuthMiddleware, RejectHeldOrderHandler)
+	router.GET("/api/v1/quote", AuthMiddleware, GetQuoteHandler)
 	router.GET("api/v1/user", AuthMiddleware, GetProfileHandler)
+	router.GET("/api/v1/user/trade-url", AuthMiddleware, GetTradeURLHandler)
+	router.PUT("/api/v1/user/trade-url", AuthMiddleware, PutTradeURLHandler)
 	router.GET("/api/v1/users", AuthMiddleware, GetUsersHandler)
 	router.GET("/api/v1/users/:id", AuthMiddleware, GetUserDetailHandler)
 	router.GET("/api/v1/users/:id/transactions", AuthMiddleware, GetUserTransactionsHandler)
+	router.GET("/api/v1/users/:id/limits", AuthMiddleware, GetUserLimitsHandler)
+	router.PUT("/api/v1/users/:id/limits", AuthMiddleware, PutUserLimitsHandler)
+	router.GET("/api/v1/users/:id/balance", AuthMiddleware, GetUserBalanceHandler)
+	router.POST("/api/v1/users/:id/balance/entries", AuthMiddleware, PostUserBalanceEntryHandler)
+	router.POST("/api/v1/users/:id/balance/reconcile", AuthMiddleware, PostUserBalanceReconcileHandler)
 	router.POST("/api/v1/payment/cb", PaymentCallbackHandler)
+	router.GET("/api/v1/admin/pricing/dry-run", AuthMiddleware, GetPricingDryRunHandler)
+	router.PUT("/api/v1/admin/price", AuthMiddleware, PutPriceHandler)
+	router.PUT("/api/v1/admin/stock", AuthMiddleware, PutStockHandler)
+	router.PUT("/api/v1/admin/sales", AuthMiddleware, PutSalesHandler)
+	router.GET("/api/v1/admin/bots", AuthMiddleware, GetBotsHandler)
+	router.PUT("/api/v1/admin/bots/:id", AuthMiddleware, PutBotHandler)
+	router.GET("/api/v1/admin/bots/:id/inventory", AuthMiddleware, GetBotInventoryHandler)
+	router.GET("/api/v1/admin/inventory", AuthMiddleware, GetInventoryHandler)
+	router.POST("/api/v1/admin/inventory/sync", AuthMiddleware, PostInventorySyncHandler)
+	router.GET("/api/v1/admin/blocks", AuthMiddleware, GetBlocksHandler)
+	router.POST("/api/v1/admin/blocks", AuthMiddleware, PostBlockHandler)
+	router.POST("/api/v1/admin/blocks/:id/lift", AuthMiddleware, LiftBlockHandler)
 
 	router.Run(":" + port)
 	UpdateStatusCache()
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"yt-api/internal/migrations"
+	"yt-api/internal/model"
+	"yt-api/internal/orders"
+
+	_ "github.com/joho/godotenv/autoload"
+)
+
+const usage = `usage: migrate <command> [flags]
+
+commands:
+  status          列出 schema migration 的套用狀態
+  up [-to N]      套用尚未套用的 schema migration
+  down [-steps N] 還原最近套用的 schema migration
+  legacy-orders   將舊 orders collection 的訂單轉移至 orderv2`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	model.InitDB()
+	model.InitRedis()
+	defer model.CloseRedis()
+
+	ctx := context.Background()
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "status":
+		status(ctx)
+	case "up":
+		fs := flag.NewFlagSet("up", flag.ExitOnError)
+		to := fs.Int("to", 0, "只套用到此版本，0 表示全部")
+		fs.Parse(args)
+		done, err := migrations.Up(ctx, *to)
+		report("Applied", done, err)
+	case "down":
+		fs := flag.NewFlagSet("down", flag.ExitOnError)
+		steps := fs.Int("steps", 1, "還原的版本數")
+		fs.Parse(args)
+		done, err := migrations.Down(ctx, *steps)
+		report("Reverted", done, err)
+	case "legacy-orders":
+		legacyOrders(ctx, args)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// status 列出每個版本是否已套用
+func status(ctx context.Context) {
+	applied, err := migrations.Applied(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, m := range migrations.All() {
+		if r, ok := applied[m.Version]; ok {
+			fmt.Printf("%4d  applied  %s  %s\n", m.Version, r.AppliedAt.Format("2006/01/02 15:04:05"), m.Name)
+		} else {
+			fmt.Printf("%4d  pending  %19s  %s\n", m.Version, "", m.Name)
+		}
+	}
+}
+
+// report 印出已完成的版本，發生錯誤時以非零狀態結束
+func report(action string, done []migrations.Migration, err error) {
+	for _, m := range done {
+		log.Printf("%s migration %d %s", action, m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(done) == 0 {
+		log.Println("Nothing to do")
+	}
+}
+
+// legacyOrders 將舊 orders collection 的訂單轉移至 orderv2 並比對結果
+func legacyOrders(ctx context.Context, args []string) {
+	fs := flag.NewFlagSet("legacy-orders", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "只統計會轉移的訂單，不寫入資料")
+	batchSize := fs.Int("batch", 100, "每批處理的訂單數量")
+	resume := fs.Bool("resume", false, "從上次記錄的 checkpoint 之後繼續")
+	verifyOnly := fs.Bool("verify", false, "只執行比對，不轉移訂單")
+	fs.Parse(args)
+
+	if !*verifyOnly {
+		result, err := orders.MigrateLegacy(ctx, orders.MigrateOptions{
+			DryRun:    *dryRun,
+			BatchSize: *batchSize,
+			Resume:    *resume,
+		})
+		if err != nil {
+			log.Fatalf("Migration stopped after %s: %v", result.LastID.Hex(), err)
+		}
+		log.Printf("Migration finished (dry-run=%v): scanned=%d inserted=%d existing=%d conflicts=%d skipped=%d",
+			*dryRun, result.Scanned, result.Inserted, result.Existing, len(result.Conflicts), len(result.Skipped))
+		for _, id := range result.Conflicts {
+			log.Println("Conflict:", id)
+		}
+		for _, id := range result.Skipped {
+			log.Println("Skipped (no TradeNo):", id)
+		}
+		if *dryRun {
+			return
+		}
+	}
+
+	v, err := orders.VerifyLegacy(ctx)
+	if err != nil {
+		log.Fatal("Verification failed: ", err)
+	}
+	log.Printf("Paid orders: legacy=%d migrated=%d", v.LegacyPaid, v.MigratedPaid)
+	log.Printf("Paid total: legacy=%d migrated=%d", v.LegacyTotal, v.MigratedTotal)
+	log.Printf("Unmigrated legacy orders: %d", v.Unmigrated)
+	log.Printf("getOrders: expected=%d reported=%d", v.ExpectedOrders, v.ReportedOrders)
+	if !v.OK() {
+		log.Println("Verification mismatch")
+		os.Exit(1)
+	}
+	log.Println("Verification passed")
+}
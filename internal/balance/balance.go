@@ -0,0 +1,233 @@
+package balance
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"yt-api/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 分錄類型
+const (
+	TypePurchaseCredit  = "purchase_credit"
+	TypeDeliveryDebit   = "delivery_debit"
+	TypeRefundDebit     = "refund_debit"
+	TypeAdminAdjustment = "admin_adjustment"
+	TypePromoCredit     = "promo_credit"
+	// TypeDeliveryReversal 為出貨失敗時退回預扣的數量
+	TypeDeliveryReversal = "delivery_reversal"
+)
+
+const (
+	ledgerCollection  = "key_ledger"
+	balanceCollection = "key_balances"
+	userAccountPrefix = "user:"
+	systemSales       = "system:sales"
+	systemDeliveries  = "system:deliveries"
+	systemAdjustments = "system:adjustments"
+	systemPromotions  = "system:promotions"
+)
+
+var (
+	ErrInvalidEntry        = errors.New("invalid ledger entry")
+	ErrDuplicate           = errors.New("ledger entry already posted")
+	ErrInsufficientBalance = errors.New("insufficient key balance")
+)
+
+// counterparty 為每種分錄對應的系統帳戶，每筆交易在用戶與系統帳戶各記一筆，金額相加為 0
+var counterparty = map[string]string{
+	TypePurchaseCredit:   systemSales,
+	TypeDeliveryDebit:    systemDeliveries,
+	TypeRefundDebit:      systemSales,
+	TypeAdminAdjustment:  systemAdjustments,
+	TypePromoCredit:      systemPromotions,
+	TypeDeliveryReversal: systemDeliveries,
+}
+
+// Entry 為 key_ledger 中的一筆分錄，只新增不修改，Amount 為正數表示入帳
+type Entry struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TxID      primitive.ObjectID `bson:"TxID" json:"txId"`
+	Account   string             `bson:"Account" json:"account"`
+	SteamID   string             `bson:"SteamID,omitempty" json:"steamId,omitempty"`
+	Type      string             `bson:"Type" json:"type"`
+	Amount    int                `bson:"Amount" json:"amount"`
+	Ref       string             `bson:"Ref,omitempty" json:"ref,omitempty"`
+	Actor     string             `bson:"Actor,omitempty" json:"actor,omitempty"`
+	Reason    string             `bson:"Reason,omitempty" json:"reason,omitempty"`
+	CreatedAt time.Time          `bson:"CreatedAt" json:"createdAt"`
+}
+
+// Transfer 為一筆要記帳的交易，除了 admin_adjustment 以外 Amount 皆為正數，方向由 Type 決定
+type Transfer struct {
+	SteamID string
+	Type    string
+	Amount  int
+	// Ref 為來源單據，例如 order:<Data_id>，同一帳戶相同 Type 與 Ref 只會記帳一次
+	Ref    string
+	Actor  string
+	Reason string
+	// RequireBalance 為 true 時扣帳不可超過餘額，退款一律檢查
+	RequireBalance bool
+}
+
+// UserAccount 回傳用戶的帳戶名稱
+func UserAccount(steamID string) string {
+	return userAccountPrefix + steamID
+}
+
+// signedAmount 回傳用戶帳戶的入帳金額
+func signedAmount(t Transfer) (int, error) {
+	if t.SteamID == "" {
+		return 0, ErrInvalidEntry
+	}
+	switch t.Type {
+	case TypePurchaseCredit, TypePromoCredit, TypeDeliveryReversal:
+		if t.Amount <= 0 {
+			return 0, ErrInvalidEntry
+		}
+		return t.Amount, nil
+	case TypeDeliveryDebit, TypeRefundDebit:
+		if t.Amount <= 0 {
+			return 0, ErrInvalidEntry
+		}
+		return -t.Amount, nil
+	case TypeAdminAdjustment:
+		if t.Amount == 0 {
+			return 0, ErrInvalidEntry
+		}
+		return t.Amount, nil
+	}
+	return 0, ErrInvalidEntry
+}
+
+// requiresBalance 回傳扣帳前是否需要檢查餘額，退款一律檢查
+func requiresBalance(t Transfer) bool {
+	return t.RequireBalance || t.Type == TypeRefundDebit
+}
+
+// checkBalance 確認入帳後的餘額不為負數
+func checkBalance(current, amount int) error {
+	if current+amount < 0 {
+		return ErrInsufficientBalance
+	}
+	return nil
+}
+
+// Post 在同一個 transaction 中寫入用戶與系統帳戶的分錄並更新餘額，
+// 已記帳的 Ref 回傳 ErrDuplicate，需要檢查餘額的扣帳超過餘額時回傳 ErrInsufficientBalance
+func Post(ctx context.Context, t Transfer) (*Entry, error) {
+	amount, err := signedAmount(t)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	txID := primitive.NewObjectID()
+	user := Entry{
+		ID:        primitive.NewObjectID(),
+		TxID:      txID,
+		Account:   UserAccount(t.SteamID),
+		SteamID:   t.SteamID,
+		Type:      t.Type,
+		Amount:    amount,
+		Ref:       t.Ref,
+		Actor:     t.Actor,
+		Reason:    t.Reason,
+		CreatedAt: now,
+	}
+	system := Entry{
+		ID:        primitive.NewObjectID(),
+		TxID:      txID,
+		Account:   counterparty[t.Type],
+		Type:      t.Type,
+		Amount:    -amount,
+		Ref:       t.Ref,
+		Actor:     t.Actor,
+		Reason:    t.Reason,
+		CreatedAt: now,
+	}
+
+	session, err := model.Db.Client().StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if requiresBalance(t) {
+			current, err := accountBalance(sc, user.Account)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkBalance(current, amount); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := model.Db.Collection(ledgerCollection).InsertMany(sc, []interface{}{user, system}); err != nil {
+			return nil, err
+		}
+		for _, e := range []Entry{user, system} {
+			set := bson.M{"UpdatedAt": now}
+			if e.SteamID != "" {
+				set["SteamID"] = e.SteamID
+			}
+			_, err := model.Db.Collection(balanceCollection).UpdateOne(sc,
+				bson.M{"_id": e.Account},
+				bson.M{"$inc": bson.M{"Balance": e.Amount}, "$set": set},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrDuplicate
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Get 回傳用戶目前的鑰匙餘額
+func Get(ctx context.Context, steamID string) (int, error) {
+	return accountBalance(ctx, UserAccount(steamID))
+}
+
+// Entries 回傳用戶帳戶的分錄，由新到舊排序
+func Entries(ctx context.Context, steamID string) ([]Entry, error) {
+	cursor, err := model.Db.Collection(ledgerCollection).Find(ctx,
+		bson.M{"Account": UserAccount(steamID)},
+		options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []Entry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func accountBalance(ctx context.Context, account string) (int, error) {
+	var doc struct {
+		Balance int `bson:"Balance"`
+	}
+	err := model.Db.Collection(balanceCollection).FindOne(ctx, bson.M{"_id": account}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return doc.Balance, err
+}
@@ -0,0 +1,150 @@
+package balance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"yt-api/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestSignedAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       Transfer
+		want    int
+		wantErr bool
+	}{
+		{"purchase credit", Transfer{SteamID: "1", Type: TypePurchaseCredit, Amount: 5}, 5, false},
+		{"promo credit", Transfer{SteamID: "1", Type: TypePromoCredit, Amount: 1}, 1, false},
+		{"delivery reversal", Transfer{SteamID: "1", Type: TypeDeliveryReversal, Amount: 2}, 2, false},
+		{"delivery debit", Transfer{SteamID: "1", Type: TypeDeliveryDebit, Amount: 3}, -3, false},
+		{"refund debit", Transfer{SteamID: "1", Type: TypeRefundDebit, Amount: 3}, -3, false},
+		{"negative adjustment", Transfer{SteamID: "1", Type: TypeAdminAdjustment, Amount: -4}, -4, false},
+		{"zero adjustment", Transfer{SteamID: "1", Type: TypeAdminAdjustment}, 0, true},
+		{"negative credit", Transfer{SteamID: "1", Type: TypePurchaseCredit, Amount: -5}, 0, true},
+		{"negative debit", Transfer{SteamID: "1", Type: TypeDeliveryDebit, Amount: -5}, 0, true},
+		{"missing user", Transfer{Type: TypePurchaseCredit, Amount: 5}, 0, true},
+		{"unknown type", Transfer{SteamID: "1", Type: "gift", Amount: 5}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := signedAmount(tt.t)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("signedAmount() = %d, %v, want %d, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequireBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       Transfer
+		current int
+		want    error
+	}{
+		{"debit within balance", Transfer{SteamID: "1", Type: TypeDeliveryDebit, Amount: 5, RequireBalance: true}, 5, nil},
+		{"debit over balance", Transfer{SteamID: "1", Type: TypeDeliveryDebit, Amount: 6, RequireBalance: true}, 5, ErrInsufficientBalance},
+		{"refund over balance", Transfer{SteamID: "1", Type: TypeRefundDebit, Amount: 1}, 0, ErrInsufficientBalance},
+		{"adjustment over balance", Transfer{SteamID: "1", Type: TypeAdminAdjustment, Amount: -3, RequireBalance: true}, 2, ErrInsufficientBalance},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !requiresBalance(tt.t) {
+				t.Fatal("requiresBalance() = false, want true")
+			}
+			amount, err := signedAmount(tt.t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := checkBalance(tt.current, amount); got != tt.want {
+				t.Errorf("checkBalance(%d, %d) = %v, want %v", tt.current, amount, got, tt.want)
+			}
+		})
+	}
+
+	// 沒有要求時出貨扣帳可讓餘額為負數，由帳本檢查發現
+	if requiresBalance(Transfer{SteamID: "1", Type: TypeDeliveryDebit, Amount: 1}) {
+		t.Error("requiresBalance() = true for a debit without RequireBalance")
+	}
+}
+
+// useTestMongo 連接 MONGO_TEST_URL 並使用暫時的資料庫，未設定時略過測試；Post 使用 transaction，需連接 replica set
+func useTestMongo(t *testing.T) {
+	t.Helper()
+	mongoURL := os.Getenv("MONGO_TEST_URL")
+	if mongoURL == "" {
+		t.Skip("MONGO_TEST_URL not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database(fmt.Sprintf("balance_test_%d", time.Now().UnixNano()))
+
+	// 與 migration 建立的唯一索引相同
+	_, err = db.Collection(ledgerCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "Account", Value: 1}, {Key: "Type", Value: 1}, {Key: "Ref", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"Ref": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := model.Db
+	model.Db = db
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		db.Drop(ctx)
+		client.Disconnect(ctx)
+		model.Db = previous
+	})
+}
+
+func TestPost(t *testing.T) {
+	useTestMongo(t)
+	ctx := context.Background()
+	const steamID = "76561198000000001"
+
+	credit := Transfer{SteamID: steamID, Type: TypePurchaseCredit, Amount: 5, Ref: OrderRef("20240101000000")}
+	if _, err := Post(ctx, credit); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	if _, err := Post(ctx, credit); err != ErrDuplicate {
+		t.Errorf("Post() same ref error = %v, want ErrDuplicate", err)
+	}
+
+	// 同一張單據在其他用戶或其他類型可記帳
+	if _, err := Post(ctx, Transfer{SteamID: "76561198000000002", Type: TypePurchaseCredit, Amount: 1, Ref: credit.Ref}); err != nil {
+		t.Errorf("Post() same ref for another user error = %v", err)
+	}
+	if _, err := Post(ctx, Transfer{SteamID: steamID, Type: TypeRefundDebit, Amount: 1, Ref: credit.Ref}); err != nil {
+		t.Errorf("Post() refund with the order ref error = %v", err)
+	}
+
+	if _, err := Post(ctx, Transfer{SteamID: steamID, Type: TypeDeliveryDebit, Amount: 5, Ref: "request:r1", RequireBalance: true}); err != ErrInsufficientBalance {
+		t.Errorf("Post() over balance error = %v, want ErrInsufficientBalance", err)
+	}
+	if got, err := Get(ctx, steamID); err != nil || got != 4 {
+		t.Errorf("Get() = %d, %v, want 4", got, err)
+	}
+
+	report, err := Check(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("Check() = %+v, want consistent ledger", report)
+	}
+}
@@ -0,0 +1,118 @@
+package balance
+
+import (
+	"context"
+
+	"yt-api/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Mismatch 表示帳戶的分錄加總與儲存的餘額不一致
+type Mismatch struct {
+	Account      string
+	Ledger       int
+	Materialized int
+}
+
+// Report 為一致性檢查的結果
+type Report struct {
+	Accounts int
+	// Total 為所有分錄的加總，複式記帳下應為 0
+	Total        int
+	Mismatches   []Mismatch
+	UnbalancedTx []primitive.ObjectID
+}
+
+// OK 回傳帳本是否一致
+func (r Report) OK() bool {
+	return r.Total == 0 && len(r.Mismatches) == 0 && len(r.UnbalancedTx) == 0
+}
+
+// Check 比對每個帳戶的分錄加總與 key_balances，並確認每筆交易的分錄加總為 0
+func Check(ctx context.Context) (Report, error) {
+	var report Report
+
+	ledger, err := sumBy(ctx, "$Account")
+	if err != nil {
+		return report, err
+	}
+
+	cursor, err := model.Db.Collection(balanceCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return report, err
+	}
+	defer cursor.Close(ctx)
+	materialized := make(map[string]int)
+	for cursor.Next(ctx) {
+		var doc struct {
+			Account string `bson:"_id"`
+			Balance int    `bson:"Balance"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return report, err
+		}
+		materialized[doc.Account] = doc.Balance
+	}
+	if err := cursor.Err(); err != nil {
+		return report, err
+	}
+
+	for account, sum := range ledger {
+		report.Total += sum
+		if materialized[account] != sum {
+			report.Mismatches = append(report.Mismatches, Mismatch{Account: account, Ledger: sum, Materialized: materialized[account]})
+		}
+	}
+	for account, value := range materialized {
+		if _, ok := ledger[account]; !ok && value != 0 {
+			report.Mismatches = append(report.Mismatches, Mismatch{Account: account, Materialized: value})
+		}
+	}
+	report.Accounts = len(materialized)
+
+	txCursor, err := model.Db.Collection(ledgerCollection).Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$group", Value: bson.M{"_id": "$TxID", "total": bson.M{"$sum": "$Amount"}}}},
+		bson.D{{Key: "$match", Value: bson.M{"total": bson.M{"$ne": 0}}}},
+	})
+	if err != nil {
+		return report, err
+	}
+	defer txCursor.Close(ctx)
+	for txCursor.Next(ctx) {
+		var tx struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := txCursor.Decode(&tx); err != nil {
+			return report, err
+		}
+		report.UnbalancedTx = append(report.UnbalancedTx, tx.ID)
+	}
+	return report, txCursor.Err()
+}
+
+// sumBy 依欄位加總分錄金額
+func sumBy(ctx context.Context, field string) (map[string]int, error) {
+	cursor, err := model.Db.Collection(ledgerCollection).Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$group", Value: bson.M{"_id": field, "total": bson.M{"$sum": "$Amount"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sums := make(map[string]int)
+	for cursor.Next(ctx) {
+		var row struct {
+			ID    string `bson:"_id"`
+			Total int    `bson:"total"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+		sums[row.ID] = row.Total
+	}
+	return sums, cursor.Err()
+}
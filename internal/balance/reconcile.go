@@ -0,0 +1,156 @@
+package balance
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"yt-api/internal/deliveries"
+	"yt-api/internal/model"
+	"yt-api/internal/orders"
+	"yt-api/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// OrderRef 回傳訂單入帳使用的 Ref
+func OrderRef(orderID string) string {
+	return "order:" + orderID
+}
+
+// DeliveryRef 回傳出貨扣帳使用的 Ref
+func DeliveryRef(d deliveries.Delivery) string {
+	return "delivery:" + d.ID.Hex()
+}
+
+// deliveryDebit 回傳出貨紀錄的扣帳
+func deliveryDebit(d deliveries.Delivery, actor string) Transfer {
+	return Transfer{
+		SteamID: d.SteamID,
+		Type:    TypeDeliveryDebit,
+		Amount:  d.Count,
+		Ref:     DeliveryRef(d),
+		Actor:   actor,
+	}
+}
+
+// DebitDelivery 在寫入已交易的出貨紀錄時扣帳，已扣帳時不重複寫入，由 deliveries.OnTraded 呼叫
+func DebitDelivery(ctx context.Context, d deliveries.Delivery) error {
+	_, err := Post(ctx, deliveryDebit(d, "deliveries"))
+	if err == ErrDuplicate {
+		return nil
+	}
+	return err
+}
+
+// Reconcile 為已付款且不在審核中的訂單補上購買入帳，並為已交易的出貨紀錄補上扣帳，
+// 已記帳的項目不會重複寫入，出貨請求產生的紀錄已在建立請求時扣帳，steamID 為空時處理所有用戶
+func Reconcile(ctx context.Context, steamID string) (int, error) {
+	posted, err := postedRefs(ctx, steamID)
+	if err != nil {
+		return 0, err
+	}
+
+	list, err := orders.Find(ctx, steamID)
+	if err != nil {
+		return 0, err
+	}
+	delivered, err := deliveries.List(ctx, steamID)
+	if err != nil {
+		return 0, err
+	}
+	transfers := missingTransfers(list, delivered, posted)
+
+	// 同時有其他程序補帳時會得到 ErrDuplicate，不計入本次寫入的數量
+	count := 0
+	for _, t := range transfers {
+		_, err := Post(ctx, t)
+		if err == ErrDuplicate {
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// missingTransfers 回傳尚未記帳的購買入帳與出貨扣帳，審核中的訂單與出貨請求產生的紀錄不補帳
+func missingTransfers(list []orders.Order, delivered []deliveries.Delivery, posted map[string]bool) []Transfer {
+	var transfers []Transfer
+	for _, o := range list {
+		if o.Status != orders.StatusPaid || o.Count <= 0 {
+			continue
+		}
+		ref := OrderRef(o.OrderId)
+		if !posted[postedKey(TypePurchaseCredit, o.SteamID, ref)] {
+			transfers = append(transfers, Transfer{
+				SteamID: o.SteamID,
+				Type:    TypePurchaseCredit,
+				Amount:  o.Count,
+				Ref:     ref,
+				Actor:   "reconcile",
+			})
+		}
+	}
+	for _, d := range delivered {
+		if !d.Traded || d.Count <= 0 || d.RequestID != "" {
+			continue
+		}
+		if !posted[postedKey(TypeDeliveryDebit, d.SteamID, DeliveryRef(d))] {
+			transfers = append(transfers, deliveryDebit(d, "reconcile"))
+		}
+	}
+	return transfers
+}
+
+// postedKey 回傳 postedRefs 使用的鍵，與 key_ledger 的 Account、Type、Ref 唯一索引對應
+func postedKey(entryType, steamID, ref string) string {
+	return entryType + "|" + steamID + "|" + ref
+}
+
+// postedRefs 讀取已記帳的 Type、SteamID 與 Ref 組合
+func postedRefs(ctx context.Context, steamID string) (map[string]bool, error) {
+	filter := bson.M{
+		"SteamID": bson.M{"$exists": true},
+		"Type":    bson.M{"$in": bson.A{TypePurchaseCredit, TypeDeliveryDebit}},
+	}
+	if steamID != "" {
+		filter["SteamID"] = steamID
+	}
+	cursor, err := model.Db.Collection(ledgerCollection).Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	posted := make(map[string]bool)
+	for cursor.Next(ctx) {
+		var e Entry
+		if err := cursor.Decode(&e); err != nil {
+			return nil, err
+		}
+		posted[postedKey(e.Type, e.SteamID, e.Ref)] = true
+	}
+	return posted, cursor.Err()
+}
+
+// StartReconcile 定期為所有用戶補上尚未記帳的購買與出貨，間隔由 BALANCE_RECONCILE_INTERVAL 設定
+func StartReconcile() {
+	interval := utils.GetEnvDuration("BALANCE_RECONCILE_INTERVAL", 5*time.Minute)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			if n, err := Reconcile(ctx, ""); err != nil {
+				log.Println("Error reconciling key balances:", err)
+			} else if n > 0 {
+				log.Printf("Posted %d missing ledger entries", n)
+			}
+			cancel()
+		}
+	}()
+}
@@ -0,0 +1,55 @@
+package balance
+
+import (
+	"testing"
+
+	"yt-api/internal/deliveries"
+	"yt-api/internal/orders"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMissingTransfers(t *testing.T) {
+	const steamID = "76561198000000001"
+	legacyDelivery := deliveries.Delivery{ID: primitive.NewObjectID(), SteamID: steamID, Count: 3, Traded: true}
+	postedDelivery := deliveries.Delivery{ID: primitive.NewObjectID(), SteamID: steamID, Count: 1, Traded: true}
+
+	list := []orders.Order{
+		{SteamID: steamID, OrderId: "20240101000000", Count: 5, Status: orders.StatusPaid},
+		{SteamID: steamID, OrderId: "20240102000000", Count: 5, Status: orders.StatusPaid},
+		{SteamID: steamID, OrderId: "20240103000000", Count: 5, Status: orders.StatusHeld},
+		{SteamID: steamID, OrderId: "20240104000000", Count: 5, Status: orders.StatusRejected},
+		{SteamID: steamID, OrderId: "20240105000000", Count: 5, Status: orders.StatusUnpaid},
+		{SteamID: steamID, OrderId: "20240106000000", Status: orders.StatusPaid},
+	}
+	delivered := []deliveries.Delivery{
+		legacyDelivery,
+		postedDelivery,
+		{ID: primitive.NewObjectID(), SteamID: steamID, RequestID: "r1", Count: 2, Traded: true},
+		{ID: primitive.NewObjectID(), SteamID: steamID, Count: 2},
+	}
+	posted := map[string]bool{
+		postedKey(TypePurchaseCredit, steamID, OrderRef("20240102000000")): true,
+		postedKey(TypeDeliveryDebit, steamID, DeliveryRef(postedDelivery)): true,
+	}
+
+	got := missingTransfers(list, delivered, posted)
+	want := []Transfer{
+		{SteamID: steamID, Type: TypePurchaseCredit, Amount: 5, Ref: OrderRef("20240101000000"), Actor: "reconcile"},
+		{SteamID: steamID, Type: TypeDeliveryDebit, Amount: 3, Ref: DeliveryRef(legacyDelivery), Actor: "reconcile"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("missingTransfers() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("transfer %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// 同一張單據在其他用戶已記帳不影響
+	other := map[string]bool{postedKey(TypePurchaseCredit, "76561198000000002", OrderRef("20240101000000")): true}
+	if got := missingTransfers(list[:1], nil, other); len(got) != 1 {
+		t.Errorf("missingTransfers() with another user's entry = %+v, want 1 transfer", got)
+	}
+}
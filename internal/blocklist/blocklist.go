@@ -0,0 +1,107 @@
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"yt-api/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Block 表示 blocklist collection 中的一筆封鎖紀錄
+type Block struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SteamID    string             `bson:"SteamID" json:"steamId"`
+	Reason     string             `bson:"Reason" json:"reason"`
+	Actor      string             `bson:"Actor" json:"actor"`
+	CreatedAt  time.Time          `bson:"CreatedAt" json:"createdAt"`
+	ExpiresAt  *time.Time         `bson:"ExpiresAt,omitempty" json:"expiresAt,omitempty"`
+	LiftedAt   *time.Time         `bson:"LiftedAt,omitempty" json:"liftedAt,omitempty"`
+	LiftedBy   string             `bson:"LiftedBy,omitempty" json:"liftedBy,omitempty"`
+	LiftReason string             `bson:"LiftReason,omitempty" json:"liftReason,omitempty"`
+}
+
+var ErrBlockNotFound = errors.New("block not found")
+
+func collection() *mongo.Collection {
+	return model.Db.Collection("blocklist")
+}
+
+// activeFilter 為尚未解除且未過期的封鎖條件
+func activeFilter(now time.Time) bson.M {
+	return bson.M{
+		"LiftedAt": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"ExpiresAt": bson.M{"$exists": false}},
+			bson.M{"ExpiresAt": bson.M{"$gt": now}},
+		},
+	}
+}
+
+// Active 回傳 SteamID 目前生效中的封鎖，沒有封鎖時回傳 nil
+func Active(ctx context.Context, steamID string) (*Block, error) {
+	filter := activeFilter(time.Now())
+	filter["SteamID"] = steamID
+
+	var block Block
+	err := collection().FindOne(ctx, filter).Decode(&block)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// Add 新增一筆封鎖
+func Add(ctx context.Context, block Block) (*Block, error) {
+	block.ID = primitive.NewObjectID()
+	block.CreatedAt = time.Now()
+	if _, err := collection().InsertOne(ctx, block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// List 列出封鎖紀錄，activeOnly 為 true 時只列出生效中的封鎖
+func List(ctx context.Context, activeOnly bool) ([]Block, error) {
+	filter := bson.M{}
+	if activeOnly {
+		filter = activeFilter(time.Now())
+	}
+	cursor, err := collection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	blocks := []Block{}
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// Lift 解除封鎖
+func Lift(ctx context.Context, id primitive.ObjectID, actor, reason string) (*Block, error) {
+	now := time.Now()
+	var block Block
+	err := collection().FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "LiftedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"LiftedAt": now, "LiftedBy": actor, "LiftReason": reason}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&block)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrBlockNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
@@ -0,0 +1,101 @@
+package blocklist
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"yt-api/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestActiveFilter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	filter := activeFilter(now)
+	if filter["LiftedAt"].(bson.M)["$exists"] != false {
+		t.Errorf("activeFilter() LiftedAt = %v, want not lifted", filter["LiftedAt"])
+	}
+	or := filter["$or"].(bson.A)
+	if len(or) != 2 {
+		t.Fatalf("activeFilter() $or = %v", or)
+	}
+	if got := or[1].(bson.M)["ExpiresAt"].(bson.M)["$gt"]; got != now {
+		t.Errorf("activeFilter() expiry compared with %v, want %v", got, now)
+	}
+}
+
+// useTestMongo 連接 MONGO_TEST_URL 並使用暫時的資料庫，未設定時略過測試
+func useTestMongo(t *testing.T) {
+	t.Helper()
+	mongoURL := os.Getenv("MONGO_TEST_URL")
+	if mongoURL == "" {
+		t.Skip("MONGO_TEST_URL not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := model.Db
+	model.Db = client.Database(fmt.Sprintf("blocklist_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		model.Db.Drop(ctx)
+		client.Disconnect(ctx)
+		model.Db = previous
+	})
+}
+
+func TestBlocklist(t *testing.T) {
+	useTestMongo(t)
+	ctx := context.Background()
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	if block, err := Active(ctx, "76561198000000001"); err != nil || block != nil {
+		t.Fatalf("Active() without blocks = %v, %v", block, err)
+	}
+
+	// 已過期的封鎖不生效
+	if _, err := Add(ctx, Block{SteamID: "76561198000000001", Reason: "expired", ExpiresAt: &past}); err != nil {
+		t.Fatal(err)
+	}
+	if block, err := Active(ctx, "76561198000000001"); err != nil || block != nil {
+		t.Errorf("Active() with expired block = %v, %v", block, err)
+	}
+
+	temporary, err := Add(ctx, Block{SteamID: "76561198000000001", Reason: "chargeback", ExpiresAt: &future})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block, err := Active(ctx, "76561198000000001"); err != nil || block == nil || block.ID != temporary.ID {
+		t.Errorf("Active() = %v, %v, want temporary block", block, err)
+	}
+	if _, err := Add(ctx, Block{SteamID: "76561198000000002", Reason: "fraud"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if active, err := List(ctx, true); err != nil || len(active) != 2 {
+		t.Errorf("List(active) = %d blocks, %v, want 2", len(active), err)
+	}
+	if all, err := List(ctx, false); err != nil || len(all) != 3 {
+		t.Errorf("List(all) = %d blocks, %v, want 3", len(all), err)
+	}
+
+	lifted, err := Lift(ctx, temporary.ID, "admin", "resolved")
+	if err != nil || lifted.LiftedAt == nil || lifted.LiftedBy != "admin" {
+		t.Fatalf("Lift() = %+v, %v", lifted, err)
+	}
+	if block, err := Active(ctx, "76561198000000001"); err != nil || block != nil {
+		t.Errorf("Active() after lift = %v, %v", block, err)
+	}
+	if _, err := Lift(ctx, temporary.ID, "admin", "again"); err != ErrBlockNotFound {
+		t.Errorf("Lift() twice error = %v, want ErrBlockNotFound", err)
+	}
+}
@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"yt-api/internal/model"
+	"yt-api/internal/utils"
+)
+
+const heartbeatKey = "BOT_HEARTBEATS"
+
+var (
+	// Timeout 為判定機器人離線的心跳間隔
+	Timeout = utils.GetEnvDuration("BOT_HEARTBEAT_TIMEOUT", 90*time.Second)
+	// AutoPause 為 true 時已登記的機器人全部離線會暫停販售，可用 BOT_AUTO_PAUSE=false 關閉
+	AutoPause = os.Getenv("BOT_AUTO_PAUSE") != "false"
+)
+
+// Heartbeat 為機器人定期回報的狀態
+type Heartbeat struct {
+	BotID          string    `json:"botId"`
+	Version        string    `json:"version"`
+	Account        string    `json:"account"`
+	SteamID        string    `json:"steamId,omitempty"`
+	InventoryCount int       `json:"inventoryCount"`
+	QueueLagMs     int64     `json:"queueLagMs"`
+	ReceivedAt     time.Time `json:"receivedAt"`
+	// Stock 為各商品可出貨的數量，key 為商品 ID
+	Stock map[string]int `json:"stock,omitempty"`
+}
+
+// Status 為機器人最後一次心跳與是否在線
+type Status struct {
+	Heartbeat
+	Enabled bool `json:"enabled"`
+	Online  bool `json:"online"`
+}
+
+// Healthy 回傳機器人是否啟用且在線
+func (s Status) Healthy() bool {
+	return s.Enabled && s.Online
+}
+
+// Beat 記錄一次心跳並更新庫存
+func Beat(ctx context.Context, hb Heartbeat) error {
+	if err := register(ctx, hb); err != nil {
+		return err
+	}
+
+	hb.ReceivedAt = time.Now()
+	data, err := json.Marshal(hb)
+	if err != nil {
+		return err
+	}
+	if err := model.RedisClient.HSet(ctx, heartbeatKey, hb.BotID, data).Err(); err != nil {
+		return err
+	}
+	return SyncStock(ctx)
+}
+
+// List 回傳所有登記的機器人與最後一次心跳，依 BotID 排序
+func List(ctx context.Context) ([]Status, error) {
+	accounts, err := Accounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values, err := model.RedisClient.HGetAll(ctx, heartbeatKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []Status{}
+	for botID, account := range accounts {
+		status := Status{Heartbeat: Heartbeat{BotID: botID, Account: account.Account, SteamID: account.SteamID}, Enabled: account.Enabled}
+		if value, ok := values[botID]; ok {
+			if err := json.Unmarshal([]byte(value), &status.Heartbeat); err != nil {
+				log.Printf("Error parsing heartbeat of bot %s: %v", botID, err)
+			}
+			status.Online = time.Since(status.ReceivedAt) < Timeout
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].BotID < statuses[j].BotID })
+	return statuses, nil
+}
+
+// ShouldPause 回傳是否應因機器人離線暫停販售，需開啟 AutoPause、至少登記一個機器人且沒有任何機器人啟用並在線，
+// 尚未登記機器人時不暫停
+func ShouldPause(statuses []Status) bool {
+	if !AutoPause || len(statuses) == 0 {
+		return false
+	}
+	for _, s := range statuses {
+		if s.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// StartMonitor 定期檢查心跳，記錄機器人上線與離線，並將離線機器人的庫存移出總庫存
+func StartMonitor() {
+	go func() {
+		ticker := time.NewTicker(Timeout / 3)
+		defer ticker.Stop()
+		online := make(map[string]bool)
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			statuses, err := List(ctx)
+			if err == nil {
+				err = SyncStock(ctx)
+			}
+			cancel()
+			if err != nil {
+				log.Println("Error reading bot heartbeats:", err)
+				continue
+			}
+			for _, s := range statuses {
+				if previous, ok := online[s.BotID]; !ok || previous != s.Online {
+					if s.Online {
+						log.Printf("Bot %s (%s) is online", s.BotID, s.Account)
+					} else {
+						log.Printf("Bot %s (%s) is offline, last heartbeat at %s", s.BotID, s.Account, s.ReceivedAt.Format(time.RFC3339))
+					}
+					online[s.BotID] = s.Online
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"yt-api/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const registryCollection = "bots"
+
+var ErrBotNotFound = errors.New("bot not found")
+
+// Account 為 bots collection 中登記的機器人帳號，第一次回報心跳時自動登記，停用後不計入庫存也不分派出貨
+type Account struct {
+	ID      string `bson:"_id" json:"id"`
+	Account string `bson:"Account" json:"account"`
+	// SteamID 為機器人帳號的 SteamID64，用來同步 Steam 庫存
+	SteamID   string    `bson:"SteamID,omitempty" json:"steamId,omitempty"`
+	Enabled   bool      `bson:"Enabled" json:"enabled"`
+	CreatedAt time.Time `bson:"CreatedAt" json:"createdAt"`
+	UpdatedAt time.Time `bson:"UpdatedAt" json:"updatedAt"`
+}
+
+// register 登記機器人並更新登入的帳號
+func register(ctx context.Context, hb Heartbeat) error {
+	now := time.Now()
+	set := bson.M{"Account": hb.Account, "UpdatedAt": now}
+	if hb.SteamID != "" {
+		set["SteamID"] = hb.SteamID
+	}
+	_, err := model.Db.Collection(registryCollection).UpdateOne(ctx,
+		bson.M{"_id": hb.BotID},
+		bson.M{
+			"$set":         set,
+			"$setOnInsert": bson.M{"Enabled": true, "CreatedAt": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// Accounts 回傳所有登記的機器人
+func Accounts(ctx context.Context) (map[string]Account, error) {
+	cursor, err := model.Db.Collection(registryCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var list []Account
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	accounts := make(map[string]Account, len(list))
+	for _, a := range list {
+		accounts[a.ID] = a
+	}
+	return accounts, nil
+}
+
+// SetEnabled 啟用或停用機器人，回傳變更前的設定
+func SetEnabled(ctx context.Context, botID string, enabled bool) (*Account, error) {
+	var previous Account
+	err := model.Db.Collection(registryCollection).FindOneAndUpdate(ctx,
+		bson.M{"_id": botID},
+		bson.M{"$set": bson.M{"Enabled": enabled, "UpdatedAt": time.Now()}},
+	).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrBotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"context"
+	"errors"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/model"
+	"yt-api/internal/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 出貨分派策略
+const (
+	StrategyMostStock  = "most_stock"
+	StrategyRoundRobin = "round_robin"
+	StrategySticky     = "sticky"
+)
+
+const (
+	roundRobinKey   = "BOT_ROUND_ROBIN"
+	stickyKeyPrefix = "BOT_STICKY:"
+)
+
+// Strategy 為出貨分派策略，由 BOT_ROUTING 設定
+var Strategy = utils.GetEnv("BOT_ROUTING", StrategyMostStock)
+
+var ErrNoBotAvailable = errors.New("no bot with enough stock")
+
+// Route 依分派策略選出庫存足夠的機器人；沒有任何機器人登記時回傳空字串，由共用的 stream 分派
+func Route(ctx context.Context, steamID string, count int) (string, error) {
+	statuses, err := List(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(statuses) == 0 {
+		return "", nil
+	}
+
+	candidates := eligible(statuses, count)
+	if len(candidates) == 0 {
+		return "", ErrNoBotAvailable
+	}
+
+	switch Strategy {
+	case StrategyRoundRobin:
+		n, err := model.RedisClient.Incr(ctx, roundRobinKey).Result()
+		if err != nil {
+			return "", err
+		}
+		return selectBot(Strategy, candidates, n, ""), nil
+
+	case StrategySticky:
+		key := stickyKeyPrefix + steamID
+		previous, err := model.RedisClient.Get(ctx, key).Result()
+		if err != nil && err != redis.Nil {
+			return "", err
+		}
+		botID := selectBot(Strategy, candidates, 0, previous)
+		if botID != previous {
+			if err := model.RedisClient.Set(ctx, key, botID, 0).Err(); err != nil {
+				return "", err
+			}
+		}
+		return botID, nil
+	}
+	return selectBot(Strategy, candidates, 0, ""), nil
+}
+
+// eligible 回傳啟用、在線且庫存足夠的機器人，List 已依 BotID 排序，輪流分派時順序固定
+func eligible(statuses []Status, count int) []Status {
+	var candidates []Status
+	for _, s := range statuses {
+		if s.Healthy() && s.Stock[catalog.DefaultProductID] >= count {
+			candidates = append(candidates, s)
+		}
+	}
+	return candidates
+}
+
+// selectBot 依策略從 candidates 中選出機器人，turn 為輪流分派的序號 (從 1 開始)，
+// sticky 為用戶上次分派的機器人；同一位用戶盡量由同一台機器人出貨，該機器人無法出貨時改選庫存最多的
+func selectBot(strategy string, candidates []Status, turn int64, sticky string) string {
+	switch strategy {
+	case StrategyRoundRobin:
+		return candidates[int((turn-1)%int64(len(candidates)))].BotID
+	case StrategySticky:
+		for _, s := range candidates {
+			if s.BotID == sticky {
+				return sticky
+			}
+		}
+	}
+	return mostStock(candidates)
+}
+
+// mostStock 回傳庫存最多的機器人
+func mostStock(candidates []Status) string {
+	best := candidates[0]
+	for _, s := range candidates[1:] {
+		if s.Stock[catalog.DefaultProductID] > best.Stock[catalog.DefaultProductID] {
+			best = s
+		}
+	}
+	return best.BotID
+}
@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"testing"
+
+	"yt-api/internal/catalog"
+)
+
+func status(botID string, enabled, online bool, stock int) Status {
+	s := Status{Enabled: enabled, Online: online}
+	s.BotID = botID
+	s.Stock = map[string]int{catalog.DefaultProductID: stock}
+	return s
+}
+
+func botIDs(statuses []Status) []string {
+	ids := make([]string, 0, len(statuses))
+	for _, s := range statuses {
+		ids = append(ids, s.BotID)
+	}
+	return ids
+}
+
+func TestEligible(t *testing.T) {
+	statuses := []Status{
+		status("a", true, true, 10),
+		status("b", false, true, 50),
+		status("c", true, false, 50),
+		status("d", true, true, 3),
+		status("e", true, true, 5),
+	}
+	tests := []struct {
+		name  string
+		count int
+		want  []string
+	}{
+		{"all healthy with stock", 1, []string{"a", "d", "e"}},
+		{"exact stock qualifies", 5, []string{"a", "e"}},
+		{"disabled and offline bots excluded", 20, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := botIDs(eligible(statuses, tt.count))
+			if len(got) != len(tt.want) {
+				t.Fatalf("eligible(%d) = %v, want %v", tt.count, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("eligible(%d) = %v, want %v", tt.count, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectBot(t *testing.T) {
+	candidates := []Status{
+		status("a", true, true, 10),
+		status("b", true, true, 30),
+		status("c", true, true, 30),
+	}
+	tests := []struct {
+		name     string
+		strategy string
+		turn     int64
+		sticky   string
+		want     string
+	}{
+		{"most stock picks first of ties", StrategyMostStock, 0, "", "b"},
+		{"unknown strategy falls back to most stock", "random", 0, "", "b"},
+		{"round robin first turn", StrategyRoundRobin, 1, "", "a"},
+		{"round robin third turn", StrategyRoundRobin, 3, "", "c"},
+		{"round robin wraps", StrategyRoundRobin, 4, "", "a"},
+		{"sticky keeps previous bot", StrategySticky, 0, "a", "a"},
+		{"sticky without previous picks most stock", StrategySticky, 0, "", "b"},
+		{"sticky bot unavailable picks most stock", StrategySticky, 0, "z", "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectBot(tt.strategy, candidates, tt.turn, tt.sticky); got != tt.want {
+				t.Errorf("selectBot(%q, turn=%d, sticky=%q) = %q, want %q", tt.strategy, tt.turn, tt.sticky, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldPause(t *testing.T) {
+	defer func(v bool) { AutoPause = v }(AutoPause)
+
+	tests := []struct {
+		name      string
+		autoPause bool
+		statuses  []Status
+		want      bool
+	}{
+		{"no bots registered", true, nil, false},
+		{"one healthy bot", true, []Status{status("a", true, false, 0), status("b", true, true, 0)}, false},
+		{"all bots offline", true, []Status{status("a", true, false, 0), status("b", true, false, 0)}, true},
+		{"only disabled bot online", true, []Status{status("a", false, true, 0)}, true},
+		{"auto pause disabled", false, []Status{status("a", true, false, 0)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			AutoPause = tt.autoPause
+			if got := ShouldPause(tt.statuses); got != tt.want {
+				t.Errorf("ShouldPause() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
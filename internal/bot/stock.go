@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"context"
+	"strconv"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/model"
+	"yt-api/internal/stock"
+)
+
+// OnStockChanged 在機器人庫存加總變更後呼叫，用來讓狀態快取失效
+var OnStockChanged func()
+
+// SyncStock 將啟用且在線的機器人庫存加總後寫入各商品的 stock.BotsKey，作為可售數量的上限；
+// StockKey 由販售與管理員維護，這裡不會覆寫。沒有任何機器人回報過的商品不設上限
+func SyncStock(ctx context.Context) error {
+	statuses, err := List(ctx)
+	if err != nil {
+		return err
+	}
+
+	totals := make(map[string]int)
+	for _, s := range statuses {
+		if !s.Enabled {
+			continue
+		}
+		for productID, count := range s.Stock {
+			if s.Online {
+				totals[productID] += count
+			} else if _, ok := totals[productID]; !ok {
+				totals[productID] = 0
+			}
+		}
+	}
+	if len(totals) == 0 {
+		return nil
+	}
+
+	products, err := catalog.List(ctx)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, product := range products {
+		total, ok := totals[product.ID]
+		if !ok {
+			continue
+		}
+		key := stock.BotsKey(product)
+		previous, _ := model.RedisClient.Get(ctx, key).Result()
+		if previous == strconv.Itoa(total) {
+			continue
+		}
+		if err := model.RedisClient.Set(ctx, key, total, 0).Err(); err != nil {
+			return err
+		}
+		changed = true
+	}
+	if changed && OnStockChanged != nil {
+		OnStockChanged()
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+
+	"yt-api/internal/model"
+	"yt-api/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultProductID 為沒有指定商品的舊訂單與請求所對應的商品
+const DefaultProductID = "tf2-key"
+
+// Product 表示 products collection 中的一項商品
+type Product struct {
+	ID         string `bson:"_id" json:"id"`
+	Name       string `bson:"name" json:"name"`
+	PriceKey   string `bson:"priceKey" json:"priceKey"`
+	StockKey   string `bson:"stockKey" json:"stockKey"`
+	TiersKey   string `bson:"tiersKey" json:"tiersKey"`
+	ItemNameID int    `bson:"itemNameId" json:"itemNameId"`
+	// MarketHashName 用來從機器人的 Steam 庫存中找出這項商品
+	MarketHashName string `bson:"marketHashName" json:"marketHashName"`
+	MinCount       int    `bson:"minCount" json:"minCount"`
+	MaxCount       int    `bson:"maxCount" json:"maxCount"`
+	Enabled        bool   `bson:"enabled" json:"enabled"`
+}
+
+var ErrProductNotFound = errors.New("product not found")
+
+// DefaultProduct 為 Mann Co. 鑰匙，沿用原本的 Redis key
+var DefaultProduct = Product{
+	ID:             DefaultProductID,
+	Name:           "Mann Co. Supply Crate Key",
+	PriceKey:       "REDIS_PRICE",
+	StockKey:       "REDIS_STOCK",
+	TiersKey:       "REDIS_PRICE_TIERS",
+	ItemNameID:     1,
+	MarketHashName: "Mann Co. Supply Crate Key",
+	MinCount:       1,
+	MaxCount:       utils.GetEnvInt("MAX_ORDER_COUNT", 100),
+	Enabled:        true,
+}
+
+// List 回傳所有上架中的商品，預設商品一定排在第一個
+func List(ctx context.Context) ([]Product, error) {
+	cursor, err := model.Db.Collection("products").Find(ctx, bson.M{"enabled": true})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stored []Product
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, err
+	}
+
+	products := []Product{DefaultProduct}
+	for _, p := range stored {
+		if p.ID == DefaultProductID {
+			products[0] = p
+			continue
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
+
+// Get 依 ID 取得商品，空字串代表預設商品
+func Get(ctx context.Context, id string) (*Product, error) {
+	if id == "" {
+		id = DefaultProductID
+	}
+
+	var product Product
+	err := model.Db.Collection("products").FindOne(ctx, bson.M{"_id": id}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		if id == DefaultProductID {
+			product = DefaultProduct
+			return &product, nil
+		}
+		return nil, ErrProductNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !product.Enabled {
+		return nil, ErrProductNotFound
+	}
+	return &product, nil
+}
+
+// OrderFilter 回傳篩選某商品訂單的條件，舊訂單沒有 ProductID 欄位，視為預設商品
+func OrderFilter(id string) bson.M {
+	if id == "" || id == DefaultProductID {
+		return bson.M{"ProductID": bson.M{"$in": bson.A{nil, DefaultProductID}}}
+	}
+	return bson.M{"ProductID": id}
+}
@@ -0,0 +1,373 @@
+package deliveries
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"yt-api/internal/model"
+	"yt-api/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 出貨紀錄的來源
+const (
+	SourceAPI          = "api"
+	SourceTranscations = "transcations"
+	SourceUsers        = "users"
+)
+
+const collectionName = "deliveries"
+
+// syncCheckpointID 為 migration_checkpoints 中記錄 transcations 同步進度的文件 ID
+const syncCheckpointID = "deliveries-sync"
+
+// syncOverlap 為同步時往前多讀的時間，避免寫入時間與 checkpoint 相近的紀錄被略過
+const syncOverlap = time.Minute
+
+// Delivery 為 deliveries collection 中的一筆出貨紀錄，SourceKey 用來避免從舊資料重複寫入
+type Delivery struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SteamID string             `bson:"SteamID" json:"steamId"`
+	TradeID string             `bson:"TradeID" json:"tradeId"`
+	// RequestID 為對應的出貨請求，數量已在建立請求時扣帳
+	RequestID string    `bson:"RequestID,omitempty" json:"requestId,omitempty"`
+	Count     int       `bson:"Count" json:"count"`
+	Traded    bool      `bson:"Traded" json:"traded"`
+	Source    string    `bson:"Source" json:"source"`
+	SourceKey string    `bson:"SourceKey" json:"-"`
+	CreatedAt time.Time `bson:"CreatedAt" json:"createdAt"`
+	UpdatedAt time.Time `bson:"UpdatedAt" json:"updatedAt"`
+}
+
+// legacyTransaction 為 transcations collection 的文件
+type legacyTransaction struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	SteamID   string             `bson:"steamID"`
+	TradeID   string             `bson:"tradeId"`
+	RequestID string             `bson:"requestId,omitempty"`
+	Count     int                `bson:"Count"`
+	Traded    bool               `bson:"traded"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt"`
+}
+
+// embeddedTransaction 為 users.Transaction 陣列中的元素
+type embeddedTransaction struct {
+	TradeID string `bson:"TradeID"`
+	Count   int    `bson:"Count"`
+	Traded  bool   `bson:"Traded"`
+}
+
+// OnTraded 在寫入或同步到已交易且不是由出貨請求產生的紀錄後被呼叫，用來扣除用戶的鑰匙餘額；
+// 出貨請求產生的紀錄已在建立請求時扣帳
+var OnTraded func(ctx context.Context, d Delivery) error
+
+// notifyTraded 在紀錄需要扣帳時呼叫 OnTraded，失敗時由 balance.Reconcile 補上
+func notifyTraded(ctx context.Context, d Delivery) {
+	if OnTraded == nil || !d.Traded || d.RequestID != "" || d.Count <= 0 {
+		return
+	}
+	if err := OnTraded(ctx, d); err != nil {
+		log.Printf("Error debiting delivery %s: %v", d.ID.Hex(), err)
+	}
+}
+
+// Legacy 為 true 時仍在切換期間，同時讀寫 users.Transaction 與 transcations
+var Legacy = os.Getenv("DELIVERIES_LEGACY") != "false"
+
+// Record 寫入一筆出貨紀錄，切換期間同時寫入 transcations 讓舊的讀取端仍看得到；
+// deliveries 先寫入，Sync 不會在兩次寫入之間建立缺少 RequestID 的紀錄
+func Record(ctx context.Context, steamID, tradeID, requestID string, count int, traded bool) (*Delivery, error) {
+	now := time.Now()
+	d := &Delivery{
+		ID:        primitive.NewObjectID(),
+		SteamID:   steamID,
+		TradeID:   tradeID,
+		RequestID: requestID,
+		Count:     count,
+		Traded:    traded,
+		Source:    SourceAPI,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	d.SourceKey = SourceAPI + ":" + d.ID.Hex()
+
+	legacyID := primitive.NewObjectID()
+	if Legacy {
+		d.Source = SourceTranscations
+		d.SourceKey = transcationsKey(legacyID)
+	}
+
+	if _, err := model.Db.Collection(collectionName).InsertOne(ctx, d); err != nil {
+		return nil, err
+	}
+	if !Legacy {
+		notifyTraded(ctx, *d)
+		return d, nil
+	}
+
+	// 舊的 getTransactions 會加總 users.Transaction 與 transcations，只寫入 transcations 以免重複計算
+	_, err := model.Db.Collection("transcations").InsertOne(ctx, bson.M{
+		"_id":       legacyID,
+		"steamID":   steamID,
+		"tradeId":   tradeID,
+		"requestId": requestID,
+		"Count":     count,
+		"traded":    traded,
+		"createdAt": now,
+		"updatedAt": now,
+		"__v":       0,
+	})
+	if err != nil {
+		if _, delErr := model.Db.Collection(collectionName).DeleteOne(ctx, bson.M{"_id": d.ID}); delErr != nil {
+			log.Printf("Error removing delivery %s after transcations insert failed: %v", d.ID.Hex(), delErr)
+		}
+		return nil, err
+	}
+	notifyTraded(ctx, *d)
+	return d, nil
+}
+
+// Sync 將舊資料中尚未寫入或已變更的出貨紀錄同步到 deliveries，steamID 為空時同步全部；
+// transcations 只讀取上次同步後更新的紀錄，users.Transaction 只讀取與上次同步內容不同的用戶
+func Sync(ctx context.Context, steamID string) (int, error) {
+	synced := 0
+	startedAt := time.Now()
+
+	since, err := loadSyncCheckpoint(ctx)
+	if err != nil {
+		return synced, err
+	}
+	filter := bson.M{}
+	if !since.IsZero() {
+		filter["updatedAt"] = bson.M{"$gte": since.Add(-syncOverlap)}
+	}
+	if steamID != "" {
+		filter["steamID"] = steamID
+	}
+	cursor, err := model.Db.Collection("transcations").Find(ctx, filter)
+	if err != nil {
+		return synced, err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var t legacyTransaction
+		if err := cursor.Decode(&t); err != nil {
+			return synced, err
+		}
+		createdAt := t.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = t.ID.Timestamp()
+		}
+		n, err := upsert(ctx, Delivery{
+			SteamID:   t.SteamID,
+			TradeID:   t.TradeID,
+			RequestID: t.RequestID,
+			Count:     t.Count,
+			Traded:    t.Traded,
+			Source:    SourceTranscations,
+			SourceKey: transcationsKey(t.ID),
+			CreatedAt: createdAt,
+		})
+		if err != nil {
+			return synced, err
+		}
+		synced += n
+	}
+	if err := cursor.Err(); err != nil {
+		return synced, err
+	}
+	// 只有同步全部時才推進 checkpoint，單一用戶的同步不代表其他用戶已同步
+	if steamID == "" {
+		if err := saveSyncCheckpoint(ctx, startedAt); err != nil {
+			return synced, err
+		}
+	}
+
+	// DeliveriesSynced 為上次同步時的 Transaction 內容，相同時略過
+	userFilter := bson.M{
+		"Transaction.0": bson.M{"$exists": true},
+		"$expr":         bson.M{"$ne": bson.A{"$Transaction", "$DeliveriesSynced"}},
+	}
+	if steamID != "" {
+		userFilter["SteamID"] = steamID
+	}
+	userCursor, err := model.Db.Collection("users").Find(ctx, userFilter,
+		options.Find().SetProjection(bson.M{"SteamID": 1, "Transaction": 1}))
+	if err != nil {
+		return synced, err
+	}
+	defer userCursor.Close(ctx)
+	for userCursor.Next(ctx) {
+		// Transaction 保留原始內容寫回 DeliveriesSynced，比對時才不會因為未解碼的欄位而不同
+		var user struct {
+			ID          primitive.ObjectID `bson:"_id"`
+			SteamID     string             `bson:"SteamID"`
+			Transaction bson.RawValue      `bson:"Transaction"`
+		}
+		if err := userCursor.Decode(&user); err != nil {
+			return synced, err
+		}
+		var transactions []embeddedTransaction
+		if err := user.Transaction.Unmarshal(&transactions); err != nil {
+			return synced, err
+		}
+		// 陣列只會往後新增，以索引作為來源鍵
+		for i, t := range transactions {
+			n, err := upsert(ctx, Delivery{
+				SteamID:   user.SteamID,
+				TradeID:   t.TradeID,
+				Count:     t.Count,
+				Traded:    t.Traded,
+				Source:    SourceUsers,
+				SourceKey: fmt.Sprintf("%s:%s:%d", SourceUsers, user.SteamID, i),
+				CreatedAt: user.ID.Timestamp(),
+			})
+			if err != nil {
+				return synced, err
+			}
+			synced += n
+		}
+		_, err := model.Db.Collection("users").UpdateByID(ctx, user.ID, bson.M{"$set": bson.M{"DeliveriesSynced": user.Transaction}})
+		if err != nil {
+			return synced, err
+		}
+	}
+	return synced, userCursor.Err()
+}
+
+// loadSyncCheckpoint 讀取上次同步全部 transcations 的時間，尚未同步過時回傳零值
+func loadSyncCheckpoint(ctx context.Context) (time.Time, error) {
+	var checkpoint struct {
+		Since time.Time `bson:"Since"`
+	}
+	err := model.Db.Collection("migration_checkpoints").FindOne(ctx, bson.M{"_id": syncCheckpointID}).Decode(&checkpoint)
+	if err == mongo.ErrNoDocuments {
+		return time.Time{}, nil
+	}
+	return checkpoint.Since, err
+}
+
+// saveSyncCheckpoint 記錄本次同步開始的時間
+func saveSyncCheckpoint(ctx context.Context, since time.Time) error {
+	_, err := model.Db.Collection("migration_checkpoints").UpdateOne(ctx,
+		bson.M{"_id": syncCheckpointID},
+		bson.M{"$set": bson.M{"Since": since, "UpdatedAt": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// upsert 依 SourceKey 寫入或更新一筆紀錄，回傳是否有變更，有變更且已交易時扣帳
+func upsert(ctx context.Context, d Delivery) (int, error) {
+	insert := bson.M{
+		"SteamID":   d.SteamID,
+		"TradeID":   d.TradeID,
+		"Source":    d.Source,
+		"CreatedAt": d.CreatedAt,
+	}
+	if d.RequestID != "" {
+		insert["RequestID"] = d.RequestID
+	}
+	var updated Delivery
+	err := model.Db.Collection(collectionName).FindOneAndUpdate(ctx,
+		bson.M{"SourceKey": d.SourceKey, "$or": bson.A{
+			bson.M{"Traded": bson.M{"$ne": d.Traded}},
+			bson.M{"Count": bson.M{"$ne": d.Count}},
+		}},
+		bson.M{
+			"$set":         bson.M{"Traded": d.Traded, "Count": d.Count, "UpdatedAt": time.Now()},
+			"$setOnInsert": insert,
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&updated)
+	// 內容沒有變更時查詢條件不符合，upsert 會因 SourceKey 唯一索引而失敗
+	if mongo.IsDuplicateKeyError(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	notifyTraded(ctx, updated)
+	return 1, nil
+}
+
+// StartSync 在切換期間定期同步舊資料，間隔由 DELIVERIES_SYNC_INTERVAL 設定
+func StartSync() {
+	if !Legacy {
+		return
+	}
+	interval := utils.GetEnvDuration("DELIVERIES_SYNC_INTERVAL", time.Minute)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if n, err := Sync(ctx, ""); err != nil {
+				log.Println("Error syncing deliveries:", err)
+			} else if n > 0 {
+				log.Printf("Synced %d deliveries from legacy collections", n)
+			}
+			cancel()
+		}
+	}()
+}
+
+// Claimed 回傳已成功交易的數量，steamID 為空時回傳全部；切換期間舊資料由 StartSync 定期同步，讀取時不再同步
+func Claimed(ctx context.Context, steamID string) (int, error) {
+	match := bson.M{"Traded": true}
+	if steamID != "" {
+		match["SteamID"] = steamID
+	}
+
+	cursor, err := model.Db.Collection(collectionName).Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$match", Value: match}},
+		bson.D{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$Count"}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Total int `bson:"total"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return 0, err
+		}
+	}
+	return result.Total, cursor.Err()
+}
+
+// List 回傳用戶的出貨紀錄，由新到舊排序，steamID 為空時回傳全部
+func List(ctx context.Context, steamID string) ([]Delivery, error) {
+	filter := bson.M{}
+	if steamID != "" {
+		filter["SteamID"] = steamID
+	}
+	cursor, err := model.Db.Collection(collectionName).Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var result []Delivery
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func transcationsKey(id primitive.ObjectID) string {
+	return SourceTranscations + ":" + id.Hex()
+}
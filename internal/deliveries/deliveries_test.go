@@ -0,0 +1,39 @@
+package deliveries
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNotifyTraded(t *testing.T) {
+	defer func(hook func(context.Context, Delivery) error) { OnTraded = hook }(OnTraded)
+
+	tests := []struct {
+		name string
+		d    Delivery
+		want bool
+	}{
+		{"legacy traded delivery", Delivery{SteamID: "76561198000000001", Count: 2, Traded: true}, true},
+		{"not traded", Delivery{SteamID: "76561198000000001", Count: 2}, false},
+		{"debited when the request was created", Delivery{SteamID: "76561198000000001", RequestID: "r1", Count: 2, Traded: true}, false},
+		{"no keys", Delivery{SteamID: "76561198000000001", Traded: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			OnTraded = func(ctx context.Context, d Delivery) error {
+				called = true
+				// 扣帳失敗只記錄，不影響出貨紀錄的寫入
+				return errors.New("ledger unavailable")
+			}
+			notifyTraded(context.Background(), tt.d)
+			if called != tt.want {
+				t.Errorf("OnTraded called = %v, want %v", called, tt.want)
+			}
+		})
+	}
+
+	OnTraded = nil
+	notifyTraded(context.Background(), tests[0].d)
+}
@@ -0,0 +1,336 @@
+package dispatch
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"yt-api/internal/bot"
+	"yt-api/internal/model"
+	"yt-api/internal/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 機器人以 consumer group 讀取分派給自己的 stream (StreamKey:<botID>)，沒有登記任何機器人時使用共用的 StreamKey；
+// 超過 ackTimeout 未確認的工作會重新分派，超過 maxAttempts 次後移至 dead-letter stream
+var (
+	StreamKey     = utils.GetEnv("DELIVERY_STREAM", "DELIVERY_STREAM")
+	DeadLetterKey = StreamKey + "_DLQ"
+	Group         = utils.GetEnv("DELIVERY_GROUP", "bots")
+
+	ackTimeout      = utils.GetEnvDuration("DELIVERY_ACK_TIMEOUT", 5*time.Minute)
+	maxAttempts     = utils.GetEnvInt("DELIVERY_MAX_ATTEMPTS", 3)
+	monitorInterval = utils.GetEnvDuration("DELIVERY_MONITOR_INTERVAL", 30*time.Second)
+
+	// groups 記錄已建立 consumer group 的 stream
+	groups sync.Map
+)
+
+// Job 為放入 stream 的出貨工作
+type Job struct {
+	RequestID string
+	BotID     string
+	SteamID   string
+	TradeURL  string
+	Count     int
+	Attempt   int
+}
+
+// QueueState 為工作在 stream 中的即時狀態
+type QueueState struct {
+	Pending    bool   `json:"pending"`
+	Consumer   string `json:"consumer,omitempty"`
+	IdleMs     int64  `json:"idleMs,omitempty"`
+	RetryCount int64  `json:"retryCount,omitempty"`
+}
+
+func (j Job) values() map[string]interface{} {
+	return map[string]interface{}{
+		"requestId": j.RequestID,
+		"botId":     j.BotID,
+		"steamId":   j.SteamID,
+		"tradeUrl":  j.TradeURL,
+		"count":     j.Count,
+		"attempt":   j.Attempt,
+	}
+}
+
+func jobFromValues(values map[string]interface{}) Job {
+	str := func(key string) string {
+		v, _ := values[key].(string)
+		return v
+	}
+	count, _ := strconv.Atoi(str("count"))
+	attempt, _ := strconv.Atoi(str("attempt"))
+	return Job{
+		RequestID: str("requestId"),
+		BotID:     str("botId"),
+		SteamID:   str("steamId"),
+		TradeURL:  str("tradeUrl"),
+		Count:     count,
+		Attempt:   attempt,
+	}
+}
+
+// streamFor 回傳分派給機器人的 stream
+func streamFor(botID string) string {
+	if botID == "" {
+		return StreamKey
+	}
+	return StreamKey + ":" + botID
+}
+
+// InitQueue 建立 stream 與 consumer group
+func InitQueue(ctx context.Context, stream string) error {
+	if _, ok := groups.Load(stream); ok {
+		return nil
+	}
+	err := model.RedisClient.XGroupCreateMkStream(ctx, stream, Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	groups.Store(stream, true)
+	return nil
+}
+
+// enqueue 將工作放入分派的機器人的 stream 並回傳訊息 ID
+func enqueue(ctx context.Context, job Job) (string, error) {
+	stream := streamFor(job.BotID)
+	if err := InitQueue(ctx, stream); err != nil {
+		return "", err
+	}
+	return model.RedisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		Values: job.values(),
+	}).Result()
+}
+
+// queueState 查詢訊息是否已被機器人讀取但尚未確認
+func queueState(ctx context.Context, botID, messageID string) (*QueueState, error) {
+	pending, err := model.RedisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: streamFor(botID),
+		Group:  Group,
+		Start:  messageID,
+		End:    messageID,
+		Count:  1,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return &QueueState{}, nil
+	}
+	return &QueueState{
+		Pending:    true,
+		Consumer:   pending[0].Consumer,
+		IdleMs:     pending[0].Idle.Milliseconds(),
+		RetryCount: pending[0].RetryCount,
+	}, nil
+}
+
+// StartMonitor 定期處理逾時未確認的工作，間隔由 DELIVERY_MONITOR_INTERVAL 設定
+func StartMonitor() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	err := InitQueue(ctx, StreamKey)
+	cancel()
+	if err != nil {
+		log.Println("Error creating delivery consumer group:", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(monitorInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := retryStale(ctx); err != nil {
+				log.Println("Error retrying stale deliveries:", err)
+			}
+			cancel()
+		}
+	}()
+}
+
+// retryStale 檢查共用與每台機器人的 stream
+func retryStale(ctx context.Context) error {
+	accounts, err := bot.Accounts(ctx)
+	if err != nil {
+		return err
+	}
+	streams := []string{StreamKey}
+	for botID := range accounts {
+		streams = append(streams, streamFor(botID))
+	}
+	for _, stream := range streams {
+		if err := retryStaleStream(ctx, stream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staleAction 為逾時未確認工作的處理方式
+type staleAction int
+
+const (
+	staleWait staleAction = iota
+	staleAck
+	staleRetry
+	staleDeadLetter
+)
+
+// monitorConsumer 為重新分派前認領逾時工作使用的 consumer 名稱
+const monitorConsumer = "monitor"
+
+// decideStale 決定逾時未確認的工作如何處理：請求已不在 queued、不存在或已改由其他訊息分派時只需確認；
+// 讀取工作的機器人在 ackTimeout 內仍有活動時繼續等待，避免它稍後送出報價而重複出貨；
+// 機器人已停止活動時重新分派，attempt 達到上限則移至 dead-letter stream
+func decideStale(req *Request, messageID string, consumerIdle time.Duration, attempt int) staleAction {
+	if req == nil || req.Status != StatusQueued || (req.MessageID != "" && req.MessageID != messageID) {
+		return staleAck
+	}
+	if consumerIdle < ackTimeout {
+		return staleWait
+	}
+	if attempt >= maxAttempts {
+		return staleDeadLetter
+	}
+	return staleRetry
+}
+
+// retryStaleStream 處理逾時未確認的工作，重新分派前先以 XCLAIM 認領，機器人在這段期間重新讀取時不會重複分派
+func retryStaleStream(ctx context.Context, stream string) error {
+	if err := InitQueue(ctx, stream); err != nil {
+		return err
+	}
+	stale, err := model.RedisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  Group,
+		Idle:   ackTimeout,
+		Start:  "-",
+		End:    "+",
+		Count:  100,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	consumers, err := model.RedisClient.XInfoConsumers(ctx, stream, Group).Result()
+	if err != nil {
+		return err
+	}
+	idle := make(map[string]time.Duration, len(consumers))
+	for _, c := range consumers {
+		idle[c.Name] = c.Idle
+	}
+
+	for _, p := range stale {
+		messages, err := model.RedisClient.XRangeN(ctx, stream, p.ID, p.ID, 1).Result()
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			// 訊息已被刪除，只需移出 pending 清單
+			model.RedisClient.XAck(ctx, stream, Group, p.ID)
+			continue
+		}
+
+		job := jobFromValues(messages[0].Values)
+		job.Attempt++
+		req, err := find(ctx, job.RequestID, "")
+		if err != nil && err != ErrRequestNotFound {
+			return err
+		}
+
+		// consumer 已被移除或工作由監控認領後未完成時，視為機器人已停止活動
+		consumerIdle, ok := idle[p.Consumer]
+		if !ok || p.Consumer == monitorConsumer {
+			consumerIdle = ackTimeout
+		}
+		action := decideStale(req, p.ID, consumerIdle, job.Attempt)
+		if action == staleWait {
+			continue
+		}
+		if action != staleAck {
+			claimed, err := model.RedisClient.XClaimJustID(ctx, &redis.XClaimArgs{
+				Stream:   stream,
+				Group:    Group,
+				Consumer: monitorConsumer,
+				MinIdle:  ackTimeout,
+				Messages: []string{p.ID},
+			}).Result()
+			if err != nil {
+				return err
+			}
+			if len(claimed) == 0 {
+				// 機器人剛重新讀取這份工作
+				continue
+			}
+		}
+
+		reason := "no acknowledgement from " + p.Consumer
+		switch action {
+		case staleDeadLetter:
+			if err := deadLetter(ctx, p.ID, job, reason); err != nil {
+				return err
+			}
+		case staleRetry:
+			if err := retry(ctx, job, reason); err != nil {
+				return err
+			}
+		}
+		if err := model.RedisClient.XAck(ctx, stream, Group, p.ID).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retry 將工作重新分派，原本的機器人可能已離線；沒有其他機器人可用時仍排回原本的機器人
+func retry(ctx context.Context, job Job, reason string) error {
+	if botID, err := bot.Route(ctx, job.SteamID, job.Count); err == nil {
+		job.BotID = botID
+	} else if err != bot.ErrNoBotAvailable {
+		return err
+	}
+	newID, err := enqueue(ctx, job)
+	if err != nil {
+		return err
+	}
+	ok, err := markRetried(ctx, job, newID, reason)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// 請求在分派期間已送出報價或失敗，撤回剛放入的工作
+		model.RedisClient.XDel(ctx, streamFor(job.BotID), newID)
+		return nil
+	}
+	log.Printf("Retrying delivery %s as %s (attempt %d)", job.RequestID, newID, job.Attempt+1)
+	return nil
+}
+
+// deadLetter 將請求標記失敗並退回預扣數量後移至 dead-letter stream；
+// 請求已不在 queued 時表示已由其他流程處理，不需移至 dead-letter stream
+func deadLetter(ctx context.Context, messageID string, job Job, reason string) error {
+	if err := Fail(ctx, job.RequestID, reason); err != nil {
+		if err == ErrRequestNotFound {
+			return nil
+		}
+		return err
+	}
+	values := job.values()
+	values["originalId"] = messageID
+	values["reason"] = reason
+	if err := model.RedisClient.XAdd(ctx, &redis.XAddArgs{Stream: DeadLetterKey, Values: values}).Err(); err != nil {
+		return err
+	}
+	log.Printf("Delivery %s moved to dead-letter stream after %d attempts: %s", job.RequestID, job.Attempt, reason)
+	return nil
+}
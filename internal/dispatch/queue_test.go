@@ -0,0 +1,68 @@
+package dispatch
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDecideStale(t *testing.T) {
+	defer func(timeout time.Duration, attempts int) { ackTimeout, maxAttempts = timeout, attempts }(ackTimeout, maxAttempts)
+	ackTimeout, maxAttempts = 5*time.Minute, 3
+
+	queued := &Request{Status: StatusQueued, MessageID: "2-0"}
+	tests := []struct {
+		name      string
+		req       *Request
+		messageID string
+		idle      time.Duration
+		attempt   int
+		want      staleAction
+	}{
+		{"request not found", nil, "2-0", time.Hour, 1, staleAck},
+		{"offer already sent", &Request{Status: StatusSent, MessageID: "2-0"}, "2-0", time.Hour, 1, staleAck},
+		{"already delivered", &Request{Status: StatusDelivered, MessageID: "2-0"}, "2-0", time.Hour, 3, staleAck},
+		{"already failed", &Request{Status: StatusFailed, MessageID: "2-0"}, "2-0", time.Hour, 3, staleAck},
+		{"message replaced by retry", queued, "1-0", time.Hour, 1, staleAck},
+		{"bot still active", queued, "2-0", time.Minute, 1, staleWait},
+		{"bot still active at last attempt", queued, "2-0", time.Minute, 3, staleWait},
+		{"bot inactive", queued, "2-0", 5 * time.Minute, 1, staleRetry},
+		{"bot inactive before limit", queued, "2-0", time.Hour, 2, staleRetry},
+		{"bot inactive at limit", queued, "2-0", time.Hour, 3, staleDeadLetter},
+		{"request without message id", &Request{Status: StatusQueued}, "2-0", time.Hour, 1, staleRetry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decideStale(tt.req, tt.messageID, tt.idle, tt.attempt); got != tt.want {
+				t.Errorf("decideStale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobValues(t *testing.T) {
+	job := Job{RequestID: "r1", BotID: "a", SteamID: "76561198000000001", TradeURL: "https://steamcommunity.com/tradeoffer/new/?partner=1&token=AbCd1234", Count: 5, Attempt: 2}
+
+	// stream 回傳的值都是字串
+	values := map[string]interface{}{}
+	for key, value := range job.values() {
+		switch v := value.(type) {
+		case int:
+			values[key] = strconv.Itoa(v)
+		default:
+			values[key] = v
+		}
+	}
+	if got := jobFromValues(values); got != job {
+		t.Errorf("jobFromValues() = %+v, want %+v", got, job)
+	}
+}
+
+func TestStreamFor(t *testing.T) {
+	if got := streamFor(""); got != StreamKey {
+		t.Errorf("streamFor(\"\") = %q, want %q", got, StreamKey)
+	}
+	if got := streamFor("a"); got != StreamKey+":a" {
+		t.Errorf("streamFor(\"a\") = %q", got)
+	}
+}
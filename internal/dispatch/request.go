@@ -0,0 +1,248 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"yt-api/internal/balance"
+	"yt-api/internal/bot"
+	"yt-api/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 出貨請求狀態
+const (
+	StatusQueued    = "queued"
+	StatusSent      = "sent"
+	StatusEscrow    = "escrow"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+const collectionName = "delivery_requests"
+
+var (
+	ErrRequestNotFound = errors.New("delivery request not found")
+	ErrNoTradeURL      = errors.New("trade url not set")
+)
+
+// Request 為用戶的出貨請求，建立時即從鑰匙帳本預扣數量，失敗時退回
+type Request struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SteamID   string             `bson:"SteamID" json:"steamId"`
+	Count     int                `bson:"Count" json:"count"`
+	TradeURL  string             `bson:"TradeURL" json:"-"`
+	Status    string             `bson:"Status" json:"status"`
+	MessageID string             `bson:"MessageID,omitempty" json:"-"`
+	Attempts  int                `bson:"Attempts" json:"attempts"`
+	// TradeID 與 TradeState 由機器人回報的交易報價狀態更新
+	TradeID    string `bson:"TradeID,omitempty" json:"tradeId,omitempty"`
+	TradeState string `bson:"TradeState,omitempty" json:"tradeState,omitempty"`
+	// BotID 為分派的機器人，未登記任何機器人時為空
+	BotID string `bson:"BotID,omitempty" json:"-"`
+	// Dispatches 為每次分派的機器人與訊息，重新分派後原本的機器人仍可能送出報價
+	Dispatches []Dispatch `bson:"Dispatches,omitempty" json:"-"`
+	Error      string     `bson:"Error,omitempty" json:"error,omitempty"`
+	CreatedAt  time.Time  `bson:"CreatedAt" json:"createdAt"`
+	UpdatedAt  time.Time  `bson:"UpdatedAt" json:"updatedAt"`
+	// Queue 為查詢時附上的 stream 即時狀態
+	Queue *QueueState `bson:"-" json:"queue,omitempty"`
+}
+
+// Dispatch 為一次分派到機器人 stream 的訊息
+type Dispatch struct {
+	BotID     string `bson:"BotID"`
+	MessageID string `bson:"MessageID"`
+}
+
+// assigned 回傳請求是否曾分派給機器人，未登記機器人時分派的 BotID 為空
+func (r *Request) assigned(botID string) bool {
+	if r.BotID == "" || r.BotID == botID {
+		return true
+	}
+	for _, d := range r.Dispatches {
+		if d.BotID == botID {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestRef 回傳出貨請求在帳本中使用的 Ref
+func RequestRef(id primitive.ObjectID) string {
+	return "request:" + id.Hex()
+}
+
+// Create 預扣用戶的鑰匙並將出貨工作分派給庫存足夠的機器人，
+// 餘額不足時回傳 balance.ErrInsufficientBalance，沒有機器人可出貨時回傳 bot.ErrNoBotAvailable
+func Create(ctx context.Context, steamID string, count int) (*Request, error) {
+	var user struct {
+		TradeURL string `bson:"TradeURL"`
+	}
+	err := model.Db.Collection("users").FindOne(ctx, bson.M{"SteamID": steamID}).Decode(&user)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if user.TradeURL == "" {
+		return nil, ErrNoTradeURL
+	}
+
+	botID, err := bot.Route(ctx, steamID, count)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	req := &Request{
+		ID:        primitive.NewObjectID(),
+		SteamID:   steamID,
+		Count:     count,
+		TradeURL:  user.TradeURL,
+		BotID:     botID,
+		Status:    StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	_, err = balance.Post(ctx, balance.Transfer{
+		SteamID:        steamID,
+		Type:           balance.TypeDeliveryDebit,
+		Amount:         count,
+		Ref:            RequestRef(req.ID),
+		Actor:          steamID,
+		Reason:         "delivery request",
+		RequireBalance: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := model.Db.Collection(collectionName).InsertOne(ctx, req); err != nil {
+		reverse(ctx, req.ID, steamID, count, "request not saved")
+		return nil, err
+	}
+
+	req.MessageID, err = enqueue(ctx, Job{RequestID: req.ID.Hex(), BotID: botID, SteamID: steamID, TradeURL: user.TradeURL, Count: count})
+	if err != nil {
+		Fail(ctx, req.ID.Hex(), "enqueue failed")
+		return nil, err
+	}
+	req.Dispatches = []Dispatch{{BotID: botID, MessageID: req.MessageID}}
+	_, err = model.Db.Collection(collectionName).UpdateByID(ctx, req.ID, bson.M{"$set": bson.M{"MessageID": req.MessageID, "Dispatches": req.Dispatches}})
+	return req, err
+}
+
+// Get 讀取出貨請求並附上 stream 即時狀態，steamID 不為空時只回傳該用戶的請求
+func Get(ctx context.Context, id, steamID string) (*Request, error) {
+	req, err := find(ctx, id, steamID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status == StatusQueued && req.MessageID != "" {
+		if req.Queue, err = queueState(ctx, req.BotID, req.MessageID); err != nil {
+			log.Printf("Error getting queue state for delivery %s: %v", id, err)
+		}
+	}
+	return req, nil
+}
+
+// find 讀取出貨請求，找不到時回傳 ErrRequestNotFound
+func find(ctx context.Context, id, steamID string) (*Request, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrRequestNotFound
+	}
+	filter := bson.M{"_id": oid}
+	if steamID != "" {
+		filter["SteamID"] = steamID
+	}
+
+	var req Request
+	err = model.Db.Collection(collectionName).FindOne(ctx, filter).Decode(&req)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// List 回傳用戶的出貨請求，由新到舊排序
+func List(ctx context.Context, steamID string) ([]Request, error) {
+	cursor, err := model.Db.Collection(collectionName).Find(ctx, bson.M{"SteamID": steamID},
+		options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	requests := []Request{}
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// Fail 將尚未完成的請求標記為失敗並退回預扣的數量
+func Fail(ctx context.Context, id, reason string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrRequestNotFound
+	}
+
+	var req Request
+	err = model.Db.Collection(collectionName).FindOneAndUpdate(ctx,
+		bson.M{"_id": oid, "Status": StatusQueued},
+		bson.M{"$set": bson.M{"Status": StatusFailed, "Error": reason, "UpdatedAt": time.Now()}},
+	).Decode(&req)
+	if err == mongo.ErrNoDocuments {
+		return ErrRequestNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return reverse(ctx, req.ID, req.SteamID, req.Count, reason)
+}
+
+// markRetried 記錄重新分派的機器人、訊息 ID 與次數，並加入分派紀錄；請求已不在 queued 時回傳 false
+func markRetried(ctx context.Context, job Job, messageID, reason string) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(job.RequestID)
+	if err != nil {
+		return false, nil
+	}
+	result, err := model.Db.Collection(collectionName).UpdateOne(ctx,
+		bson.M{"_id": oid, "Status": StatusQueued},
+		bson.M{
+			"$set":  bson.M{"BotID": job.BotID, "MessageID": messageID, "Attempts": job.Attempt, "Error": reason, "UpdatedAt": time.Now()},
+			"$push": bson.M{"Dispatches": Dispatch{BotID: job.BotID, MessageID: messageID}},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// reverse 退回出貨請求預扣的數量
+func reverse(ctx context.Context, id primitive.ObjectID, steamID string, count int, reason string) error {
+	_, err := balance.Post(ctx, balance.Transfer{
+		SteamID: steamID,
+		Type:    balance.TypeDeliveryReversal,
+		Amount:  count,
+		Ref:     RequestRef(id),
+		Actor:   "dispatch",
+		Reason:  reason,
+	})
+	if err != nil && err != balance.ErrDuplicate {
+		log.Printf("Error reversing delivery %s: %v", id.Hex(), err)
+		return err
+	}
+	return nil
+}
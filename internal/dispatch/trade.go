@@ -0,0 +1,170 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"yt-api/internal/deliveries"
+	"yt-api/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 機器人回報的交易報價狀態
+const (
+	TradeSent      = "sent"
+	TradeEscrow    = "escrow"
+	TradeAccepted  = "accepted"
+	TradeDeclined  = "declined"
+	TradeCountered = "countered"
+	TradeExpired   = "expired"
+)
+
+var (
+	ErrInvalidTradeState = errors.New("invalid trade state")
+	ErrTradeMismatch     = errors.New("trade or bot does not match delivery request")
+	ErrInvalidTransition = errors.New("trade state not allowed for delivery request")
+)
+
+// tradeTransitions 為每種交易狀態允許的請求狀態，以及回報後請求的新狀態
+var tradeTransitions = map[string]struct {
+	From []string
+	To   string
+}{
+	TradeSent:      {From: []string{StatusQueued}, To: StatusSent},
+	TradeEscrow:    {From: []string{StatusSent}, To: StatusEscrow},
+	TradeAccepted:  {From: []string{StatusSent, StatusEscrow}, To: StatusDelivered},
+	TradeDeclined:  {From: []string{StatusSent, StatusEscrow}, To: StatusFailed},
+	TradeCountered: {From: []string{StatusSent, StatusEscrow}, To: StatusFailed},
+	TradeExpired:   {From: []string{StatusSent, StatusEscrow}, To: StatusFailed},
+}
+
+// TradeEvent 為機器人回報的一次交易報價狀態變更
+type TradeEvent struct {
+	RequestID string
+	TradeID   string
+	State     string
+	BotID     string
+}
+
+// ReportTrade 依機器人回報的交易狀態更新出貨請求，回傳更新前後的請求；
+// 重複回報相同狀態時不做任何變更，applied 為 false。
+// 報價送出後即確認 stream 中的工作，交易成功時寫入出貨紀錄，被拒絕、還價或過期時退回預扣的數量
+func ReportTrade(ctx context.Context, ev TradeEvent) (previous, updated *Request, applied bool, err error) {
+	transition, ok := tradeTransitions[ev.State]
+	if !ok || ev.TradeID == "" {
+		return nil, nil, false, ErrInvalidTradeState
+	}
+
+	previous, err = Get(ctx, ev.RequestID, "")
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if err := checkTrade(previous, ev); err != nil {
+		return previous, nil, false, err
+	}
+	if previous.TradeState == ev.State {
+		// 退回可重複執行，重複回報時補上先前失敗的退回
+		if previous.Status == StatusFailed {
+			err = reverse(ctx, previous.ID, previous.SteamID, previous.Count, "trade "+ev.State)
+		}
+		return previous, previous, false, err
+	}
+	if _, ok := nextStatus(previous.Status, ev.State); !ok {
+		return previous, nil, false, ErrInvalidTransition
+	}
+
+	// 以 Status 條件更新，並發的回報只有一個會成功
+	var req Request
+	err = model.Db.Collection(collectionName).FindOneAndUpdate(ctx,
+		bson.M{"_id": previous.ID, "Status": bson.M{"$in": transition.From}},
+		bson.M{"$set": bson.M{
+			"Status":     transition.To,
+			"TradeID":    ev.TradeID,
+			"TradeState": ev.State,
+			"BotID":      ev.BotID,
+			"Error":      errorFor(ev.State),
+			"UpdatedAt":  time.Now(),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&req)
+	if err == mongo.ErrNoDocuments {
+		return previous, nil, false, ErrInvalidTransition
+	}
+	if err != nil {
+		return previous, nil, false, err
+	}
+
+	// 報價已送出，取消所有分派，避免其他機器人重複出貨
+	if previous.Status == StatusQueued {
+		cancelDispatches(ctx, previous)
+	}
+
+	switch req.Status {
+	case StatusDelivered:
+		if _, err := deliveries.Record(ctx, req.SteamID, req.TradeID, req.ID.Hex(), req.Count, true); err != nil {
+			return previous, &req, true, err
+		}
+	case StatusFailed:
+		if err := reverse(ctx, req.ID, req.SteamID, req.Count, "trade "+ev.State); err != nil {
+			return previous, &req, true, err
+		}
+	}
+	return previous, &req, true, nil
+}
+
+// checkTrade 檢查回報是否來自這個請求的機器人與交易報價；
+// 接受曾分派到的任何機器人回報，重新分派前讀到工作的機器人仍可能送出報價，
+// 報價送出後只接受送出報價的機器人回報
+func checkTrade(req *Request, ev TradeEvent) error {
+	if req.TradeID != "" && (req.TradeID != ev.TradeID || req.BotID != ev.BotID) {
+		return ErrTradeMismatch
+	}
+	if !req.assigned(ev.BotID) {
+		return ErrTradeMismatch
+	}
+	return nil
+}
+
+// nextStatus 回傳請求在 status 狀態下收到交易狀態 state 後的新狀態，不允許時 ok 為 false
+func nextStatus(status, state string) (string, bool) {
+	transition, ok := tradeTransitions[state]
+	if !ok {
+		return "", false
+	}
+	for _, from := range transition.From {
+		if from == status {
+			return transition.To, true
+		}
+	}
+	return "", false
+}
+
+// cancelDispatches 確認並刪除請求在每台機器人 stream 中的訊息，尚未讀取的機器人不會再收到這份工作
+func cancelDispatches(ctx context.Context, req *Request) {
+	dispatches := req.Dispatches
+	if len(dispatches) == 0 && req.MessageID != "" {
+		dispatches = []Dispatch{{BotID: req.BotID, MessageID: req.MessageID}}
+	}
+	for _, d := range dispatches {
+		stream := streamFor(d.BotID)
+		if err := model.RedisClient.XAck(ctx, stream, Group, d.MessageID).Err(); err != nil {
+			log.Printf("Error acknowledging delivery %s on %s: %v", req.ID.Hex(), stream, err)
+		}
+		if err := model.RedisClient.XDel(ctx, stream, d.MessageID).Err(); err != nil {
+			log.Printf("Error removing delivery %s from %s: %v", req.ID.Hex(), stream, err)
+		}
+	}
+}
+
+// errorFor 回傳失敗狀態寫入請求的錯誤訊息
+func errorFor(state string) string {
+	if tradeTransitions[state].To != StatusFailed {
+		return ""
+	}
+	return "trade " + state
+}
@@ -0,0 +1,95 @@
+package dispatch
+
+import "testing"
+
+func TestNextStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		state  string
+		want   string
+		ok     bool
+	}{
+		{StatusQueued, TradeSent, StatusSent, true},
+		{StatusQueued, TradeEscrow, "", false},
+		{StatusQueued, TradeAccepted, "", false},
+		{StatusQueued, TradeDeclined, "", false},
+		{StatusSent, TradeEscrow, StatusEscrow, true},
+		{StatusSent, TradeAccepted, StatusDelivered, true},
+		{StatusSent, TradeDeclined, StatusFailed, true},
+		{StatusSent, TradeCountered, StatusFailed, true},
+		{StatusSent, TradeExpired, StatusFailed, true},
+		{StatusSent, TradeSent, "", false},
+		{StatusEscrow, TradeAccepted, StatusDelivered, true},
+		{StatusEscrow, TradeExpired, StatusFailed, true},
+		{StatusEscrow, TradeSent, "", false},
+		{StatusDelivered, TradeDeclined, "", false},
+		{StatusDelivered, TradeAccepted, "", false},
+		{StatusFailed, TradeAccepted, "", false},
+		{StatusFailed, TradeSent, "", false},
+		{StatusSent, "unknown", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status+"/"+tt.state, func(t *testing.T) {
+			got, ok := nextStatus(tt.status, tt.state)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("nextStatus(%q, %q) = %q, %v, want %q, %v", tt.status, tt.state, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestErrorFor(t *testing.T) {
+	tests := []struct {
+		state string
+		want  string
+	}{
+		{TradeSent, ""},
+		{TradeEscrow, ""},
+		{TradeAccepted, ""},
+		{TradeDeclined, "trade declined"},
+		{TradeCountered, "trade countered"},
+		{TradeExpired, "trade expired"},
+	}
+	for _, tt := range tests {
+		if got := errorFor(tt.state); got != tt.want {
+			t.Errorf("errorFor(%q) = %q, want %q", tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestCheckTrade(t *testing.T) {
+	redispatched := &Request{
+		Status:     StatusQueued,
+		BotID:      "b",
+		Dispatches: []Dispatch{{BotID: "a", MessageID: "1-0"}, {BotID: "b", MessageID: "2-0"}},
+	}
+	sent := &Request{
+		Status:     StatusSent,
+		BotID:      "a",
+		TradeID:    "T1",
+		Dispatches: []Dispatch{{BotID: "a", MessageID: "1-0"}, {BotID: "b", MessageID: "2-0"}},
+	}
+
+	tests := []struct {
+		name string
+		req  *Request
+		ev   TradeEvent
+		want error
+	}{
+		{"shared stream accepts any bot", &Request{Status: StatusQueued}, TradeEvent{TradeID: "T1", BotID: "a"}, nil},
+		{"current bot", redispatched, TradeEvent{TradeID: "T1", BotID: "b"}, nil},
+		{"bot before redispatch", redispatched, TradeEvent{TradeID: "T1", BotID: "a"}, nil},
+		{"bot never dispatched", redispatched, TradeEvent{TradeID: "T1", BotID: "c"}, ErrTradeMismatch},
+		{"legacy request without dispatches", &Request{BotID: "a"}, TradeEvent{TradeID: "T1", BotID: "b"}, ErrTradeMismatch},
+		{"sending bot after offer", sent, TradeEvent{TradeID: "T1", BotID: "a"}, nil},
+		{"other dispatched bot after offer", sent, TradeEvent{TradeID: "T1", BotID: "b"}, ErrTradeMismatch},
+		{"other trade after offer", sent, TradeEvent{TradeID: "T2", BotID: "a"}, ErrTradeMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkTrade(tt.req, tt.ev); got != tt.want {
+				t.Errorf("checkTrade() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
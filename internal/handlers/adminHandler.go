@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"yt-api/internal/model"
+	"yt-api/internal/steamid"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const maxPrice = 100000
+
+var errNegativeStock = errors.New("stock cannot be negative")
+
+type setPriceRequest struct {
+	Product string `json:"product"`
+	Price   int    `json:"price"`
+	Reason  string `json:"reason"`
+}
+
+type setStockRequest struct {
+	Product string `json:"product"`
+	Stock   *int   `json:"stock"`
+	Delta   *int   `json:"delta"`
+	Reason  string `json:"reason"`
+}
+
+type setSalesRequest struct {
+	Paused bool   `json:"paused"`
+	Reason string `json:"reason"`
+}
+
+// requireAdmin 檢查登入者是否為管理員，不是則中止請求
+func requireAdmin(c *gin.Context) (string, bool) {
+	steamID, exist := c.Get("steamID")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+		return "", false
+	}
+
+	if !ADMIN_STEAM_ID_SET[steamID.(string)] {
+		c.AbortWithStatusJSON(403, gin.H{"error": "forbidden"})
+		return "", false
+	}
+
+	return steamID.(string), true
+}
+
+// resolveSteamIDParam 將 SteamID64、SteamID3、STEAM_0:x:y 或個人檔案網址轉換為 SteamID64，
+// 格式錯誤時回應 400
+func resolveSteamIDParam(c *gin.Context, raw string) (string, bool) {
+	if raw == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "steam id is required"})
+		return "", false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := steamid.Resolve(ctx, raw)
+	switch {
+	case err == nil:
+		return id.String(), true
+	case err == steamid.ErrInvalid:
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid steam id"})
+	case err == steamid.ErrVanityNotFound:
+		c.AbortWithStatusJSON(404, gin.H{"error": err.Error()})
+	default:
+		log.Println("Error resolving steam id:", err)
+		c.AbortWithStatusJSON(503, gin.H{"error": "steam unavailable"})
+	}
+	return "", false
+}
+
+// updateRedisInt 以 WATCH 交易更新 Redis 中的整數值，回傳更新前後的值
+func updateRedisInt(ctx context.Context, key string, update func(previous int) (int, error)) (previous, next int, err error) {
+	err = model.RedisClient.Watch(ctx, func(tx *redis.Tx) error {
+		value, err := tx.Get(ctx, key).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		previous = 0
+		if value != "" {
+			if previous, err = strconv.Atoi(value); err != nil {
+				return err
+			}
+		}
+
+		if next, err = update(previous); err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, next, 0)
+			return nil
+		})
+		return err
+	}, key)
+	return previous, next, err
+}
+
+// PutPriceHandler 處理 PUT /api/v1/admin/price 請求
+func PutPriceHandler(c *gin.Context) {
+	actor, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req setPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Price <= 0 || req.Price > maxPrice {
+		c.AbortWithStatusJSON(400, gin.H{"error": "price out of range"})
+		return
+	}
+	if req.Reason == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "reason is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	product, ok := lookupProduct(c, ctx, req.Product)
+	if !ok {
+		return
+	}
+
+	previous, next, err := updateRedisInt(ctx, product.PriceKey, func(int) (int, error) {
+		return req.Price, nil
+	})
+	if err != nil {
+		log.Println("Error setting price:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	model.WriteAudit(ctx, model.AuditEntry{Action: "price.set", Actor: actor, Target: product.ID, Reason: req.Reason, Previous: previous, Value: next})
+	InvalidateStatusCache()
+
+	c.JSON(http.StatusOK, gin.H{"product": product.ID, "previous": previous, "price": next})
+}
+
+// PutStockHandler 處理 PUT /api/v1/admin/stock 請求，可直接設定 stock 或以 delta 調整
+func PutStockHandler(c *gin.Context) {
+	actor, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req setStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid request body"})
+		return
+	}
+	if (req.Stock == nil) == (req.Delta == nil) {
+		c.AbortWithStatusJSON(400, gin.H{"error": "exactly one of stock or delta is required"})
+		return
+	}
+	if req.Reason == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "reason is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	product, ok := lookupProduct(c, ctx, req.Product)
+	if !ok {
+		return
+	}
+
+	previous, next, err := updateRedisInt(ctx, product.StockKey, func(previous int) (int, error) {
+		next := previous
+		if req.Stock != nil {
+			next = *req.Stock
+		} else {
+			next += *req.Delta
+		}
+		if next < 0 {
+			return 0, errNegativeStock
+		}
+		return next, nil
+	})
+	if err == errNegativeStock {
+		c.AbortWithStatusJSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error setting stock:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	model.WriteAudit(ctx, model.AuditEntry{Action: "stock.set", Actor: actor, Target: product.ID, Reason: req.Reason, Previous: previous, Value: next})
+	InvalidateStatusCache()
+
+	c.JSON(http.StatusOK, gin.H{"product": product.ID, "previous": previous, "stock": next})
+}
+
+// PutSalesHandler 處理 PUT /api/v1/admin/sales 請求，用來暫停或恢復販售
+func PutSalesHandler(c *gin.Context) {
+	actor, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req setSalesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Reason == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "reason is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	value := "0"
+	if req.Paused {
+		value = "1"
+	}
+	previous, err := model.RedisClient.Get(ctx, "REDIS_SALES_PAUSED").Result()
+	if err != nil && err != redis.Nil {
+		log.Println("Error getting sales paused:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+	if err := model.RedisClient.Set(ctx, "REDIS_SALES_PAUSED", value, 0).Err(); err != nil {
+		log.Println("Error setting sales paused:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	model.WriteAudit(ctx, model.AuditEntry{Action: "sales.pause", Actor: actor, Reason: req.Reason, Previous: previous == "1", Value: req.Paused})
+	InvalidateStatusCache()
+
+	c.JSON(http.StatusOK, gin.H{"paused": req.Paused})
+}
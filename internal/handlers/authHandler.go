@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"io/ioutil"
+	"context"
 	"log"
 	"net/http"
 	"net/url"
@@ -9,6 +9,9 @@ import (
 	"strings"
 	"time"
 
+	"yt-api/internal/steam"
+	"yt-api/internal/steamid"
+
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
 	_ "github.com/joho/godotenv/autoload"
@@ -39,17 +42,15 @@ func AuthHandler(c *gin.Context) {
 	params.Set("openid.sig", signature)
 
 	// 發送 POST 請求
-	resp, err := http.PostForm("https://steamcommunity.com/openid/login", params)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	body, err := steam.Default.PostForm(ctx, "https://steamcommunity.com/openid/login", params)
 	if err != nil {
 		log.Println("Check Steam openid error:", err)
-		c.AbortWithStatus(http.StatusUnauthorized)
-		return
-	}
-
-	// 讀取回應內容
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("Parse Steam openid response error:", err)
+		if steam.IsUnavailable(err) {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "steam unavailable"})
+			return
+		}
 		c.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
@@ -62,12 +63,18 @@ func AuthHandler(c *gin.Context) {
 	}
 
 	// 解析 Steam ID
-	steamID := strings.TrimPrefix(identity, "https://steamcommunity.com/openid/id/")
-	if steamID == "" {
+	parsedID, err := steamid.Parse(strings.TrimPrefix(identity, "https://steamcommunity.com/openid/id/"))
+	if err != nil {
 		log.Println("Cannot find steamID:", identity)
 		c.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
+	steamID := parsedID.String()
+
+	// 被封鎖的帳號不發放 session
+	if !checkNotBlocked(c, ctx, steamID) {
+		return
+	}
 
 	token := jwt.New(jwt.SigningMethodHS256)
 	claims := token.Claims.(jwt.MapClaims)
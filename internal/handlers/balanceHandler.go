@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"yt-api/internal/balance"
+	"yt-api/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type balanceEntryRequest struct {
+	Type   string `json:"type"`
+	Amount int    `json:"amount"`
+	Ref    string `json:"ref"`
+	Reason string `json:"reason"`
+}
+
+// manualEntryTypes 為管理員可以手動記帳的分錄類型，購買與出貨由系統記帳
+var manualEntryTypes = map[string]bool{
+	balance.TypeRefundDebit:     true,
+	balance.TypeAdminAdjustment: true,
+	balance.TypePromoCredit:     true,
+}
+
+// GetUserBalanceHandler 處理 GET /api/v1/users/:id/balance 請求，回傳餘額與分錄
+func GetUserBalanceHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	targetId, ok := resolveSteamIDParam(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	current, err := balance.Get(ctx, targetId)
+	if err != nil {
+		log.Printf("Error getting key balance for SteamID %s: %v", targetId, err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+	entries, err := balance.Entries(ctx, targetId)
+	if err != nil {
+		log.Printf("Error getting ledger entries for SteamID %s: %v", targetId, err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"balance": current,
+		"entries": entries,
+	})
+}
+
+// PostUserBalanceReconcileHandler 處理 POST /api/v1/users/:id/balance/reconcile 請求，
+// 立即為用戶補上尚未記帳的購買與出貨，不必等 balance.StartReconcile
+func PostUserBalanceReconcileHandler(c *gin.Context) {
+	actor, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+	targetId, ok := resolveSteamIDParam(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	posted, err := balance.Reconcile(ctx, targetId)
+	if err != nil {
+		log.Printf("Error reconciling key balance for SteamID %s: %v", targetId, err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+	current, err := balance.Get(ctx, targetId)
+	if err != nil {
+		log.Printf("Error getting key balance for SteamID %s: %v", targetId, err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	if posted > 0 {
+		model.WriteAudit(ctx, model.AuditEntry{
+			Action: "balance.reconcile",
+			Actor:  actor,
+			Target: targetId,
+			Value:  posted,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"posted":  posted,
+		"balance": current,
+	})
+}
+
+// PostUserBalanceEntryHandler 處理 POST /api/v1/users/:id/balance/entries 請求，手動記錄退款、調整或贈送
+func PostUserBalanceEntryHandler(c *gin.Context) {
+	actor, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+	targetId, ok := resolveSteamIDParam(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req balanceEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Reason == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "reason is required"})
+		return
+	}
+	if !manualEntryTypes[req.Type] {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid entry type"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entry, err := balance.Post(ctx, balance.Transfer{
+		SteamID: targetId,
+		Type:    req.Type,
+		Amount:  req.Amount,
+		Ref:     req.Ref,
+		Actor:   actor,
+		Reason:  req.Reason,
+	})
+	switch err {
+	case nil:
+	case balance.ErrInvalidEntry:
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid amount"})
+		return
+	case balance.ErrDuplicate:
+		c.AbortWithStatusJSON(409, gin.H{"error": "entry already posted"})
+		return
+	case balance.ErrInsufficientBalance:
+		c.AbortWithStatusJSON(409, gin.H{"error": "insufficient balance"})
+		return
+	default:
+		log.Printf("Error posting ledger entry for SteamID %s: %v", targetId, err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	model.WriteAudit(ctx, model.AuditEntry{
+		Action: "balance.entry",
+		Actor:  actor,
+		Target: targetId,
+		Reason: req.Reason,
+		Value:  entry,
+	})
+
+	c.JSON(http.StatusCreated, entry)
+}
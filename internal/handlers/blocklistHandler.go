@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"yt-api/internal/blocklist"
+	"yt-api/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type addBlockRequest struct {
+	SteamID   string     `json:"steamId"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type liftBlockRequest struct {
+	Reason string `json:"reason"`
+}
+
+// checkNotBlocked 確認用戶沒有被封鎖，被封鎖或發生錯誤時中止請求
+func checkNotBlocked(c *gin.Context, ctx context.Context, steamID string) bool {
+	block, err := blocklist.Active(ctx, steamID)
+	if err != nil {
+		log.Println("Error checking blocklist:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return false
+	}
+	if block != nil {
+		log.Printf("Blocked SteamID %s refused: %s", steamID, block.Reason)
+		c.AbortWithStatusJSON(403, gin.H{"error": "account blocked"})
+		return false
+	}
+	return true
+}
+
+// GetBlocksHandler 處理 GET /api/v1/admin/blocks?active=true 請求
+func GetBlocksHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	blocks, err := blocklist.List(ctx, c.Query("active") == "true")
+	if err != nil {
+		log.Println("Error listing blocks:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocks": blocks})
+}
+
+// PostBlockHandler 處理 POST /api/v1/admin/blocks 請求
+func PostBlockHandler(c *gin.Context) {
+	actor, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req addBlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Reason == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "reason is required"})
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		c.AbortWithStatusJSON(400, gin.H{"error": "expiresAt must be in the future"})
+		return
+	}
+	targetId, ok := resolveSteamIDParam(c, req.SteamID)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	block, err := blocklist.Add(ctx, blocklist.Block{
+		SteamID:   targetId,
+		Reason:    req.Reason,
+		Actor:     actor,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		log.Println("Error adding block:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	model.WriteAudit(ctx, model.AuditEntry{
+		Action: "block.add",
+		Actor:  actor,
+		Target: targetId,
+		Reason: req.Reason,
+		Value:  block,
+	})
+
+	c.JSON(http.StatusCreated, block)
+}
+
+// LiftBlockHandler 處理 POST /api/v1/admin/blocks/:id/lift 請求
+func LiftBlockHandler(c *gin.Context) {
+	actor, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid block id"})
+		return
+	}
+	var req liftBlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Reason == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "reason is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	block, err := blocklist.Lift(ctx, id, actor, req.Reason)
+	if err == blocklist.ErrBlockNotFound {
+		c.AbortWithStatusJSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error lifting block:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	model.WriteAudit(ctx, model.AuditEntry{
+		Action: "block.lift",
+		Actor:  actor,
+		Target: block.SteamID,
+		Reason: req.Reason,
+		Value:  block,
+	})
+
+	c.JSON(http.StatusOK, block)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"yt-api/internal/bot"
+	"yt-api/internal/dispatch"
+	"yt-api/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TradeEventRequest 為機器人回報交易報價狀態的內容
+type TradeEventRequest struct {
+	TradeID string `json:"tradeId" binding:"required"`
+	State   string `json:"state" binding:"required"`
+}
+
+// PostBotTradeHandler 處理 POST /api/v1/bot/deliveries/:id/trade，
+// 機器人回報交易報價狀態 (sent、escrow、accepted、declined、countered、expired)，取代直接寫入 transcations
+func PostBotTradeHandler(c *gin.Context) {
+	botID := c.GetString("botID")
+
+	var req TradeEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": "tradeId and state are required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	previous, updated, applied, err := dispatch.ReportTrade(ctx, dispatch.TradeEvent{
+		RequestID: c.Param("id"),
+		TradeID:   req.TradeID,
+		State:     req.State,
+		BotID:     botID,
+	})
+	switch err {
+	case nil:
+	case dispatch.ErrRequestNotFound:
+		c.AbortWithStatusJSON(404, gin.H{"error": err.Error()})
+		return
+	case dispatch.ErrInvalidTradeState:
+		c.AbortWithStatusJSON(400, gin.H{"error": err.Error()})
+		return
+	case dispatch.ErrTradeMismatch, dispatch.ErrInvalidTransition:
+		c.AbortWithStatusJSON(409, gin.H{"error": err.Error(), "status": previous.Status})
+		return
+	default:
+		log.Println("Error reporting trade state:", err)
+		if updated == nil {
+			c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+			return
+		}
+	}
+
+	// 重複回報相同狀態時不另外記錄
+	if applied {
+		model.WriteAudit(ctx, model.AuditEntry{
+			Action:   "delivery.trade",
+			Actor:    "bot:" + botID,
+			Target:   updated.SteamID,
+			Reason:   req.State,
+			Previous: gin.H{"requestId": previous.ID.Hex(), "status": previous.Status, "tradeId": previous.TradeID, "tradeState": previous.TradeState},
+			Value:    gin.H{"requestId": updated.ID.Hex(), "status": updated.Status, "tradeId": updated.TradeID, "tradeState": updated.TradeState},
+		})
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// HeartbeatRequest 為機器人心跳的內容
+type HeartbeatRequest struct {
+	Version        string `json:"version"`
+	Account        string `json:"account"`
+	SteamID        string `json:"steamId"`
+	InventoryCount int    `json:"inventoryCount"`
+	QueueLagMs     int64  `json:"queueLagMs"`
+	// Stock 為各商品可出貨的數量，key 為商品 ID，回報後會加總到商品庫存
+	Stock map[string]int `json:"stock"`
+}
+
+// PostBotHeartbeatHandler 處理 POST /api/v1/bot/heartbeat，超過 BOT_HEARTBEAT_TIMEOUT 沒有心跳即視為離線
+func PostBotHeartbeatHandler(c *gin.Context) {
+	var req HeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid heartbeat"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := bot.Beat(ctx, bot.Heartbeat{
+		BotID:          c.GetString("botID"),
+		Version:        req.Version,
+		Account:        req.Account,
+		SteamID:        req.SteamID,
+		InventoryCount: req.InventoryCount,
+		QueueLagMs:     req.QueueLagMs,
+		Stock:          req.Stock,
+	})
+	if err != nil {
+		log.Println("Error saving bot heartbeat:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+type setBotRequest struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"`
+}
+
+// GetBotsHandler 處理 GET /api/v1/admin/bots，回傳登記的機器人、心跳與庫存
+func GetBotsHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bots, err := bot.List(ctx)
+	if err != nil {
+		log.Println("Error listing bots:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"strategy": bot.Strategy,
+		"bots":     bots,
+	})
+}
+
+// PutBotHandler 處理 PUT /api/v1/admin/bots/:id，停用的機器人不計入庫存也不分派出貨
+func PutBotHandler(c *gin.Context) {
+	actor, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req setBotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Reason == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "reason is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	previous, err := bot.SetEnabled(ctx, c.Param("id"), req.Enabled)
+	if err == bot.ErrBotNotFound {
+		c.AbortWithStatusJSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error updating bot:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+	if err := bot.SyncStock(ctx); err != nil {
+		log.Println("Error syncing bot stock:", err)
+	}
+
+	model.WriteAudit(ctx, model.AuditEntry{Action: "bot.enable", Actor: actor, Target: previous.ID, Reason: req.Reason, Previous: previous.Enabled, Value: req.Enabled})
+
+	c.JSON(http.StatusOK, gin.H{"id": previous.ID, "enabled": req.Enabled})
+}
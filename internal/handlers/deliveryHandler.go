@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"yt-api/internal/balance"
+	"yt-api/internal/bot"
+	"yt-api/internal/dispatch"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateDeliveryRequest 為出貨請求的內容
+type CreateDeliveryRequest struct {
+	Count int `json:"count" binding:"required,min=1"`
+}
+
+// CreateMyDeliveryHandler 處理 POST /api/v2/me/deliveries，預扣尚未領取的鑰匙並交由機器人出貨
+func CreateMyDeliveryHandler(c *gin.Context) {
+	steamID, exist := c.Get("steamID")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+		return
+	}
+
+	var req CreateDeliveryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": "count must be a positive integer"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if !checkNotBlocked(c, ctx, steamID.(string)) {
+		return
+	}
+
+	request, err := dispatch.Create(ctx, steamID.(string), req.Count)
+	if err == dispatch.ErrNoTradeURL {
+		c.AbortWithStatusJSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err == balance.ErrInsufficientBalance || err == bot.ErrNoBotAvailable {
+		c.AbortWithStatusJSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error creating delivery request:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, request)
+}
+
+// GetMyDeliveriesHandler 處理 GET /api/v2/me/deliveries，回傳登入用戶的出貨請求
+func GetMyDeliveriesHandler(c *gin.Context) {
+	steamID, exist := c.Get("steamID")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	list, err := dispatch.List(ctx, steamID.(string))
+	if err != nil {
+		log.Println("Error occurred while finding delivery requests:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": list,
+	})
+}
+
+// GetMyDeliveryHandler 處理 GET /api/v2/me/deliveries/:id，排隊中的請求附上佇列狀態
+func GetMyDeliveryHandler(c *gin.Context) {
+	steamID, exist := c.Get("steamID")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// 其他用戶的請求一律回傳 404
+	request, err := dispatch.Get(ctx, c.Param("id"), steamID.(string))
+	if err == dispatch.ErrRequestNotFound {
+		c.AbortWithStatusJSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error occurred while finding delivery request:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
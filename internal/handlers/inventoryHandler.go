@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"yt-api/internal/inventory"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetInventoryHandler 處理 GET /api/v1/admin/inventory，回傳機器人最新的庫存快照與 Redis 庫存的差異
+func GetInventoryHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	report, err := inventory.Check(ctx)
+	if err != nil {
+		log.Println("Error checking inventory:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// PostInventorySyncHandler 處理 POST /api/v1/admin/inventory/sync，立即同步所有機器人的 Steam 庫存
+func PostInventorySyncHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	report, err := inventory.SyncAll(ctx)
+	if err != nil {
+		log.Println("Error syncing inventory:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetBotInventoryHandler 處理 GET /api/v1/admin/bots/:id/inventory，回傳機器人最新快照中的物品明細
+func GetBotInventoryHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	snapshot, err := inventory.Latest(ctx, c.Param("id"))
+	if err == mongo.ErrNoDocuments {
+		c.AbortWithStatusJSON(404, gin.H{"error": "inventory snapshot not found"})
+		return
+	}
+	if err != nil {
+		log.Println("Error reading inventory snapshot:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"yt-api/internal/limits"
+	"yt-api/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type setLimitsRequest struct {
+	limits.Overrides
+	Reason string `json:"reason"`
+}
+
+// checkPurchaseLimits 檢查購買限制，超過限制或發生錯誤時中止請求
+func checkPurchaseLimits(c *gin.Context, ctx context.Context, steamID string, count int) bool {
+	err := limits.Check(ctx, steamID, count)
+	if err == nil {
+		return true
+	}
+
+	var limitErr *limits.Error
+	if errors.As(err, &limitErr) {
+		c.AbortWithStatusJSON(429, gin.H{
+			"error":   "purchase limit exceeded",
+			"code":    limitErr.Code,
+			"limit":   limitErr.Limit,
+			"current": limitErr.Current,
+		})
+		return false
+	}
+
+	log.Println("Error checking purchase limits:", err)
+	c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+	return false
+}
+
+// GetUserLimitsHandler 處理 GET /api/v1/users/:id/limits 請求
+func GetUserLimitsHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	targetId, ok := resolveSteamIDParam(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	overrides, err := limits.GetOverrides(ctx, targetId)
+	if err != nil {
+		log.Printf("Error getting limits for SteamID %s: %v", targetId, err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+	effective, err := limits.Effective(ctx, targetId)
+	if err != nil {
+		log.Printf("Error getting limits for SteamID %s: %v", targetId, err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"defaults":  limits.Defaults(),
+		"overrides": overrides,
+		"effective": effective,
+	})
+}
+
+// PutUserLimitsHandler 處理 PUT /api/v1/users/:id/limits 請求，欄位為 null 表示沿用預設值
+func PutUserLimitsHandler(c *gin.Context) {
+	actor, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+	targetId, ok := resolveSteamIDParam(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req setLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Reason == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "reason is required"})
+		return
+	}
+	for _, v := range []*int{req.MaxOpenUnpaid, req.MaxKeysPerDay, req.MaxKeys30Days, req.MaxFirstOrder} {
+		if v != nil && *v < 0 {
+			c.AbortWithStatusJSON(400, gin.H{"error": "limits cannot be negative"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	previous, err := limits.SetOverrides(ctx, targetId, req.Overrides)
+	if err != nil {
+		log.Printf("Error setting limits for SteamID %s: %v", targetId, err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	model.WriteAudit(ctx, model.AuditEntry{
+		Action:   "limits.set",
+		Actor:    actor,
+		Target:   targetId,
+		Reason:   req.Reason,
+		Previous: previous,
+		Value:    req.Overrides,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"overrides": req.Overrides})
+}
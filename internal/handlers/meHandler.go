@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"yt-api/internal/orders"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMyOrdersHandler 處理 GET /api/v2/me/orders，回傳登入用戶自己的訂單
+func GetMyOrdersHandler(c *gin.Context) {
+	steamID, exist := c.Get("steamID")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	list, err := orders.Find(ctx, steamID.(string))
+	if err != nil {
+		log.Println("Error occurred while finding orders:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders": list,
+	})
+}
+
+// GetMyOrderHandler 處理 GET /api/v2/me/orders/:id，未付款時附上繳費資訊
+func GetMyOrderHandler(c *gin.Context) {
+	steamID, exist := c.Get("steamID")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	order, err := orders.Get(ctx, c.Param("id"))
+	// 其他用戶的訂單一律回傳 404
+	if err == orders.ErrOrderNotFound || (err == nil && order.SteamID != steamID.(string)) {
+		c.AbortWithStatusJSON(404, gin.H{"error": "order not found"})
+		return
+	}
+	if err != nil {
+		log.Println("Error occurred while finding order:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// GetMyBalanceHandler 處理 GET /api/v2/me/balance，回傳已領取與尚未領取的鑰匙數量
+func GetMyBalanceHandler(c *gin.Context) {
+	steamID, exist := c.Get("steamID")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+		return
+	}
+
+	balance, err := getUserBalance(steamID.(string))
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/limits"
+	"yt-api/internal/model"
+	"yt-api/internal/orders"
+	"yt-api/internal/pricing"
+	"yt-api/internal/risk"
+	"yt-api/internal/stock"
+	"yt-api/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errDataIDExhausted = errors.New("no order ID available")
+
+// riskTimeout 為建立訂單時風險評估的逾時
+var riskTimeout = utils.GetEnvDuration("RISK_TIMEOUT", 5*time.Second)
+
+type createOrderRequest struct {
+	QuoteID string `json:"quoteId"`
+	Method  string `json:"method"`
+}
+
+// newDataID 產生 YYYYMMDDHHmmss 格式且不重複的訂單編號
+func newDataID(ctx context.Context) (string, error) {
+	t := time.Now().In(utils.Taipei)
+	for i := 0; i < 10; i++ {
+		dataID := t.Format("20060102150405")
+		ok, err := model.RedisClient.SetNX(ctx, "DATA_ID:"+dataID, 1, 24*time.Hour).Result()
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return dataID, nil
+		}
+		t = t.Add(time.Second)
+	}
+	return "", errDataIDExhausted
+}
+
+// CreateOrderV2Handler 處理 POST /api/v2/me/orders 請求，依報價建立 SmilePay 訂單
+func CreateOrderV2Handler(c *gin.Context) {
+	steamID, exist := c.Get("steamID")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+		return
+	}
+
+	var req createOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.QuoteID == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "quoteId is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	// 報價在訂單寫入後才刪除，之前的檢查或金流失敗時報價仍可再使用
+	claim, err := pricing.ClaimQuote(ctx, req.QuoteID, steamID.(string))
+	if err == pricing.ErrQuoteNotFound {
+		c.AbortWithStatusJSON(410, gin.H{"error": err.Error()})
+		return
+	}
+	if err == pricing.ErrQuoteInUse {
+		c.AbortWithStatusJSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error claiming quote:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+	redeemed := false
+	defer func() {
+		if !redeemed {
+			claim.Release()
+		}
+	}()
+	quote := claim.Quote
+
+	// 報價未指定繳費方式時，以報價當下的手續費計算
+	if quote.Method == "" {
+		quote.Method = strings.ToLower(req.Method)
+		fee, ok := quote.Fees[quote.Method]
+		if !ok {
+			c.AbortWithStatusJSON(400, gin.H{"error": pricing.ErrInvalidMethod.Error()})
+			return
+		}
+		quote.Fee = fee
+		quote.Total = quote.Subtotal + fee
+	}
+
+	product, ok := lookupProduct(c, ctx, quote.ProductID)
+	if !ok {
+		return
+	}
+
+	if !checkNotBlocked(c, ctx, steamID.(string)) {
+		return
+	}
+
+	// 鎖定用戶到訂單寫入為止，同時建立的訂單不會都通過購買限制
+	lock, err := limits.Acquire(ctx, steamID.(string))
+	if err == limits.ErrOrderInProgress {
+		c.AbortWithStatusJSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error locking order creation:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+	defer lock.Release()
+
+	if !checkPurchaseLimits(c, ctx, steamID.(string), quote.Count) {
+		return
+	}
+
+	if err := checkAvailability(ctx, *product, quote.Count); err == errSalesPaused || err == errOutOfStock {
+		c.AbortWithStatusJSON(409, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		log.Println("Error checking availability:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	// 風險評估在預留庫存與建立繳費資料之前完成，使用獨立的逾時，Steam 查詢重試不會耗盡建立訂單的時間；
+	// 無法取得 Steam 資料時 Assess 會保留訂單等待審核
+	assessment := assessRisk(steamID.(string), quote.Count)
+	if assessment.Hold {
+		log.Printf("Order for %s held for review: score=%d reasons=%v", steamID, assessment.Score, assessment.Reasons)
+	}
+
+	dataID, err := newDataID(ctx)
+	if err != nil {
+		log.Println("Error generating order ID:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	// 預留庫存，訂單過期或取消時釋放，付款後轉為售出
+	if err := stock.Reserve(ctx, *product, dataID, quote.Count); err == stock.ErrOutOfStock {
+		c.AbortWithStatusJSON(409, gin.H{"error": errOutOfStock.Error()})
+		return
+	} else if err != nil {
+		log.Println("Error reserving stock:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	payment, err := utils.CreateSmilePayment(ctx, dataID, quote.Total, quote.Method)
+	if err != nil {
+		releaseReservation(*product, dataID)
+		c.AbortWithStatusJSON(502, gin.H{"error": "payment gateway unavailable"})
+		return
+	}
+
+	order := orders.V2{
+		SteamID:   steamID.(string),
+		ProductID: product.ID,
+		Price:     quote.UnitPrice,
+		Count:     quote.Count,
+		Fee:       quote.Fee,
+		QuoteID:   quote.ID,
+		CreatedAt: time.Now(),
+	}
+	order.OrderStatus.SmilePayNO = payment.SmilePayNO
+	order.OrderStatus.DataID = dataID
+	order.OrderStatus.Amount = payment.Amount
+	order.OrderStatus.PayEndDate = payment.PayEndDate
+	order.OrderStatus.PayMethod = quote.Method
+	order.OrderStatus.AtmBankNo = payment.AtmBankNo
+	order.OrderStatus.AtmNo = payment.AtmNo
+	order.OrderStatus.IbonNo = payment.IbonNo
+	order.OrderStatus.FamiNO = payment.FamiNO
+
+	// 風險分數過高的訂單付款後需管理員核准才出貨
+	order.Risk = assessment
+	order.DeliveryHold = assessment.Hold
+
+	// 繳費資料已建立，寫入訂單使用獨立的逾時，避免用戶取得繳費代碼但訂單沒有寫入
+	saveCtx, cancelSave := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelSave()
+	if _, err := model.Db.Collection("orderv2").InsertOne(saveCtx, order); err != nil {
+		releaseReservation(*product, dataID)
+		log.Println("Error inserting order:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	redeemed = true
+	if err := claim.Redeem(saveCtx); err != nil {
+		log.Printf("Error redeeming quote %s for order %s: %v", req.QuoteID, dataID, err)
+	}
+	InvalidateStatusCache()
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// assessRisk 以 RISK_TIMEOUT 為上限評估買家風險
+func assessRisk(steamID string, count int) *risk.Assessment {
+	ctx, cancel := context.WithTimeout(context.Background(), riskTimeout)
+	defer cancel()
+	return risk.Assess(ctx, steamID, count)
+}
+
+// CancelMyOrderHandler 處理 POST /api/v2/me/orders/:id/cancel，取消尚未付款的訂單並釋放預留的庫存
+func CancelMyOrderHandler(c *gin.Context) {
+	steamID, exist := c.Get("steamID")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	order, err := orders.Cancel(ctx, c.Param("id"), steamID.(string))
+	if err == orders.ErrOrderNotFound {
+		c.AbortWithStatusJSON(404, gin.H{"error": err.Error()})
+		return
+	}
+	if err == orders.ErrNotCancellable {
+		c.AbortWithStatusJSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error cancelling order:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	product, err := catalog.Get(ctx, order.ProductID)
+	if err != nil {
+		// 商品已下架時由 stock.StartReleaser 釋放
+		log.Println("Error getting product of cancelled order:", err)
+	} else {
+		releaseReservation(*product, order.OrderId)
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// releaseReservation 釋放訂單預留的庫存，失敗時由 stock.StartReleaser 補上
+func releaseReservation(product catalog.Product, orderID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := stock.Release(ctx, product, orderID); err != nil {
+		log.Printf("Error releasing stock reserved by order %s: %v", orderID, err)
+		return
+	}
+	InvalidateStatusCache()
+}
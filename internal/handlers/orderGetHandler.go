@@ -4,14 +4,16 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
-	"yt-api/internal/model"
+	"yt-api/internal/orders"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
+// order 為 v1 API 回傳的舊訂單格式，維持與舊版客戶端相容，統一後的格式只在 v2 提供；
+// orderv2 的訂單也以這個格式回傳
 type order struct {
 	SteamID     string
 	Price       int
@@ -23,10 +25,11 @@ type order struct {
 		PaymentType string
 		TradeStatus string
 		ExpireDate  string
-		PayTime 	  string
+		PayTime     string
 	}
 }
 
+// GetOrderHandler 處理 GET /api/v1/orders 請求，以 v1 格式回傳兩個 collection 中的訂單
 func GetOrderHandler(c *gin.Context) {
 	steamID, exist := c.Get("steamID")
 	if !exist {
@@ -35,39 +38,62 @@ func GetOrderHandler(c *gin.Context) {
 	}
 	id := c.Query("id")
 	if id != "" && (steamID == "76561198041578278" || steamID == "76561198047686623") {
-		log.Println("Admin override", steamID, id)
-		steamID = id
+		target, ok := resolveSteamIDParam(c, id)
+		if !ok {
+			return
+		}
+		log.Println("Admin override", steamID, target)
+		steamID = target
 	}
-	collection := model.Db.Collection("orders")
-	var query bson.M
-	if steamID == "76561198041578278" {
-		query = bson.M{}
-	} else {
-		query = bson.M{"SteamID": steamID}
+	// 第一位管理員可查看所有訂單
+	var target string
+	if steamID != "76561198041578278" {
+		target = steamID.(string)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	cursor, err := collection.Find(ctx, &query)
+	found, err := orders.Find(ctx, target)
 	if err != nil {
 		log.Println("Error occurred while finding orders:", err)
 		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
 		return
 	}
 
-	defer func() {
-		if err = cursor.Close(ctx); err != nil {
-			log.Fatal(err)
-		}
-	}()
-	var orders []order
-	if err := cursor.All(ctx, &orders); err != nil {
-		log.Println("Error occurred while reading orders:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
-		return
+	var list []order
+	for _, o := range found {
+		list = append(list, v1Order(o))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"orders": orders,
+		"orders": list,
 	})
 }
+
+// v1Order 將統一格式的訂單轉換為 v1 格式，已付款 (含審核中) 的訂單 TradeStatus 為 "1"
+func v1Order(o orders.Order) order {
+	v := order{SteamID: o.SteamID, Price: o.Price, Count: o.Count}
+	v.OrderStatus.Amt = o.Amount
+	v.OrderStatus.TradeNo = o.OrderId
+	v.OrderStatus.PaymentType = o.PayMethod
+	v.OrderStatus.ExpireDate = o.PayEndDate
+	v.OrderStatus.PayTime = o.PayDate
+	v.OrderStatus.TradeStatus = "0"
+	if o.Paid() {
+		v.OrderStatus.TradeStatus = "1"
+	}
+	if p := o.Payment; p != nil {
+		v.OrderStatus.PayInfo = p.PayInfo
+		if v.OrderStatus.PayInfo == "" {
+			// SmilePay 訂單以繳費帳號或超商代碼作為繳費資訊
+			var info []string
+			for _, s := range []string{p.AtmBankNo, p.AtmNo, p.IbonNo, p.FamiNO} {
+				if s != "" {
+					info = append(info, s)
+				}
+			}
+			v.OrderStatus.PayInfo = strings.Join(info, " ")
+		}
+	}
+	return v
+}
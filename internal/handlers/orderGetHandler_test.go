@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"testing"
+
+	"yt-api/internal/orders"
+)
+
+func TestV1Order(t *testing.T) {
+	tests := []struct {
+		name            string
+		order           orders.Order
+		wantTradeStatus string
+		wantPayInfo     string
+	}{
+		{
+			"paid v2 order",
+			orders.Order{Source: orders.SourceV2, Status: orders.StatusPaid, Amount: 300, PayDate: "2024/01/02 03:04:05"},
+			"1", "",
+		},
+		{
+			"held order counts as paid",
+			orders.Order{Source: orders.SourceV2, Status: orders.StatusHeld, Amount: 300},
+			"1", "",
+		},
+		{
+			"rejected order is not paid",
+			orders.Order{Source: orders.SourceV2, Status: orders.StatusRejected, Amount: 300},
+			"0", "",
+		},
+		{
+			"unpaid atm order",
+			orders.Order{Source: orders.SourceV2, Status: orders.StatusUnpaid, Amount: 300,
+				Payment: &orders.Payment{PayEndDate: "2024/01/05 23:59:59", AtmBankNo: "004", AtmNo: "1234567890"}},
+			"0", "004 1234567890",
+		},
+		{
+			"unpaid legacy order keeps pay info",
+			orders.Order{Source: orders.SourceLegacy, Status: orders.StatusUnpaid, Amount: 300,
+				Payment: &orders.Payment{PayEndDate: "2024/01/05", PayInfo: "ATM 004-1234"}},
+			"0", "ATM 004-1234",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.order.OrderId = "20240102030405"
+			tt.order.PayEndDate = "2024/01/05 23:59:59"
+			v := v1Order(tt.order)
+			if v.OrderStatus.TradeStatus != tt.wantTradeStatus {
+				t.Errorf("TradeStatus = %q, want %q", v.OrderStatus.TradeStatus, tt.wantTradeStatus)
+			}
+			if v.OrderStatus.PayInfo != tt.wantPayInfo {
+				t.Errorf("PayInfo = %q, want %q", v.OrderStatus.PayInfo, tt.wantPayInfo)
+			}
+			if v.OrderStatus.TradeNo != tt.order.OrderId || v.OrderStatus.Amt != 300 || v.OrderStatus.PayTime != tt.order.PayDate {
+				t.Errorf("v1Order() = %+v", v)
+			}
+			if v.OrderStatus.ExpireDate != "2024/01/05 23:59:59" {
+				t.Errorf("ExpireDate = %q", v.OrderStatus.ExpireDate)
+			}
+		})
+	}
+}
@@ -7,8 +7,14 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
+	"yt-api/internal/balance"
+	"yt-api/internal/catalog"
 	"yt-api/internal/model"
+	"yt-api/internal/orders"
+	"yt-api/internal/risk"
+	"yt-api/internal/stock"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
@@ -128,12 +134,60 @@ func PaymentCallbackHandler(c *gin.Context) {
 			"OrderStatus.Amt":          amt,
 		},
 	}
-	result := collection.FindOneAndUpdate(context.TODO(), filter, update)
-	if result.Err() != nil {
-		log.Printf("Error updating order: %v\n", result.Err())
+	var order orders.V2
+	err = collection.FindOneAndUpdate(context.TODO(), filter, update).Decode(&order)
+	if err != nil {
+		log.Printf("Error updating order: %v\n", err)
 		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8", []byte("Internal Server Error"))
 		return
 	}
 
+	// 審核未通過的訂單不出貨，預留已釋放，付款需人工退款
+	if order.Risk != nil && order.Risk.Status == risk.StatusRejected {
+		log.Printf("Payment received for rejected order %s, refund required", dataId)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte("<Roturlstatus>"+ROTURL_STATUS+"</Roturlstatus>"))
+		return
+	}
+
+	// 審核中的訂單於核准時才入帳，入帳失敗時由 balance.Reconcile 補上
+	if !order.DeliveryHold {
+		creditOrder(order)
+	}
+	sellReservation(order)
+
 	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte("<Roturlstatus>"+ROTURL_STATUS+"</Roturlstatus>"))
 }
+
+// sellReservation 將已付款訂單預留的庫存轉為售出，沒有預留時 (例如過期後才付款) 直接扣除實體庫存
+func sellReservation(order orders.V2) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	product, err := catalog.Get(ctx, order.ProductID)
+	if err != nil {
+		log.Printf("Error getting product of order %s: %v", order.OrderStatus.DataID, err)
+		return
+	}
+	if _, err := stock.Convert(ctx, *product, order.OrderStatus.DataID, order.Count); err != nil {
+		log.Printf("Error converting stock reserved by order %s: %v", order.OrderStatus.DataID, err)
+		return
+	}
+	InvalidateStatusCache()
+}
+
+// creditOrder 為已付款的訂單寫入購買入帳
+func creditOrder(order orders.V2) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := balance.Post(ctx, balance.Transfer{
+		SteamID: order.SteamID,
+		Type:    balance.TypePurchaseCredit,
+		Amount:  order.Count,
+		Ref:     balance.OrderRef(order.OrderStatus.DataID),
+		Actor:   "payment",
+	})
+	if err != nil && err != balance.ErrDuplicate {
+		log.Printf("Error crediting order %s: %v", order.OrderStatus.DataID, err)
+	}
+}
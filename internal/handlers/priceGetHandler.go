@@ -2,20 +2,23 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
-	"io"
 	"log"
 	"net/http"
 	"strconv"
 	"sync"
 	"time"
 
+	"yt-api/internal/bot"
+	"yt-api/internal/catalog"
+	"yt-api/internal/deliveries"
 	"yt-api/internal/model"
+	"yt-api/internal/orders"
+	"yt-api/internal/steam"
+	"yt-api/internal/stock"
 	. "yt-api/internal/types"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"github.com/redis/go-redis/v9"
 )
 
 var mutex = &sync.Mutex{}
@@ -32,15 +35,42 @@ var botStatusCache BotStatus = BotStatus{
 func GetPriceHandler(c *gin.Context) {
 
 	UpdateStatusCache()
+
+	// 機器人狀態不經過快取，離線時立即反映在 paused
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bots, err := bot.List(ctx)
+	if err != nil {
+		log.Println("Error reading bot heartbeats:", err)
+	}
+	online := false
+	for _, b := range bots {
+		online = online || b.Healthy()
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"price":        botStatusCache.Price,
 		"stock":        botStatusCache.Stock,
 		"orders":       botStatusCache.Orders,
 		"marketPrice":  botStatusCache.MarketPrice,
+		"marketPrices": botStatusCache.MarketPrices,
 		"transactions": botStatusCache.Transcations,
+		"paused":       botStatusCache.Paused || bot.ShouldPause(bots),
+		"products":     botStatusCache.Products,
+		"bot": gin.H{
+			"online": online,
+			"bots":   bots,
+		},
 	})
 }
 
+// InvalidateStatusCache 讓下一次請求重新讀取狀態
+func InvalidateStatusCache() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	botStatusCache.Updated = 0
+}
+
 func UpdateStatusCache() {
 	now := time.Now().Unix()
 	mutex.Lock()
@@ -50,181 +80,208 @@ func UpdateStatusCache() {
 	}
 	log.Println("Start update cache")
 
-	priceChan := make(chan int)
-	go getPrice(priceChan)
-	stockChan := make(chan int)
-	go getStock(stockChan)
-	orderChan := make(chan int)
-	go getOrders(orderChan)
-	marketPriceChan := make(chan int)
-	go getMarketPrice(marketPriceChan)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	products, err := catalog.List(ctx)
+	cancel()
+	if err != nil {
+		log.Println("Error listing products:", err)
+		products = []catalog.Product{catalog.DefaultProduct}
+	}
+
+	var items []int
+	for _, product := range products {
+		items = append(items, product.ItemNameID)
+	}
+	marketPriceChan := make(chan []MarketPrice)
+	go getMarketPrices(items, marketPriceChan)
 	transactionChan := make(chan int)
 	go getTransactions(transactionChan)
+	pausedChan := make(chan bool)
+	go getPaused(pausedChan)
+
+	statusChans := make([]chan ProductStatus, len(products))
+	for i, product := range products {
+		statusChans[i] = make(chan ProductStatus)
+		go getProductStatus(product, statusChans[i])
+	}
 
-	botStatusCache.Price = <-priceChan
-	botStatusCache.Stock = <-stockChan
-	botStatusCache.Orders = <-orderChan
-	botStatusCache.MarketPrice = <-marketPriceChan
-	botStatusCache.Transcations = <-transactionChan
+	botStatusCache.MarketPrices = <-marketPriceChan
+	if len(botStatusCache.MarketPrices) > 0 {
+		botStatusCache.MarketPrice = botStatusCache.MarketPrices[0].Price
+	}
+	botStatusCache.Paused = <-pausedChan
+
+	// 交易紀錄目前沒有商品欄位，全部計入預設商品
+	transactions := <-transactionChan
+
+	statuses := make(map[string]ProductStatus, len(products))
+	for i, product := range products {
+		status := <-statusChans[i]
+		status.MarketPrice = primaryMarketPrice(product.ItemNameID)
+		if product.ID == catalog.DefaultProductID {
+			status.Transactions = transactions
+		}
+		statuses[product.ID] = status
+	}
+	botStatusCache.Products = statuses
+
+	// 最上層欄位維持預設商品的狀態
+	defaultStatus := statuses[catalog.DefaultProductID]
+	botStatusCache.Price = defaultStatus.Price
+	botStatusCache.Stock = defaultStatus.Stock
+	botStatusCache.Orders = defaultStatus.Orders
+	botStatusCache.Transcations = defaultStatus.Transactions
 
 	botStatusCache.Updated = time.Now().Unix()
 	log.Printf("Update cache to %+v\n", botStatusCache)
 }
 
-func getStock(resultChan chan<- int) {
+// getProductStatus 取得單一商品的價格、庫存與訂單數
+func getProductStatus(product catalog.Product, resultChan chan<- ProductStatus) {
+	cached := botStatusCache.Products[product.ID]
+
+	priceChan := make(chan int)
+	go getPrice(product.PriceKey, cached.Price, priceChan)
+	stockChan := make(chan stock.Level)
+	go getStock(product, stock.Level{Physical: cached.PhysicalStock, Reserved: cached.Reserved, Available: cached.Stock}, stockChan)
+	orderChan := make(chan int)
+	go getOrders(product.ID, cached.Orders, orderChan)
+
+	level := <-stockChan
+	resultChan <- ProductStatus{
+		ID:            product.ID,
+		Name:          product.Name,
+		Price:         <-priceChan,
+		Stock:         level.Available,
+		PhysicalStock: level.Physical,
+		Reserved:      level.Reserved,
+		Orders:        <-orderChan,
+	}
+}
+
+// primaryMarketPrice 回傳物品在主要幣別下的市場價格
+func primaryMarketPrice(itemNameID int) int {
+	for _, price := range botStatusCache.MarketPrices {
+		if price.ItemNameID == itemNameID {
+			return price.Price
+		}
+	}
+	return 0
+}
+
+// getStock 取得實體庫存與預留數量，可售數量為兩者相減
+func getStock(product catalog.Product, cached stock.Level, resultChan chan<- stock.Level) {
 	ctx := context.Background()
 
 	// 從 Redis 獲取庫存數據
-	stockStr, err := model.RedisClient.Get(ctx, "REDIS_STOCK").Result()
+	level, err := stock.Get(ctx, product)
 	if err != nil {
 		log.Printf("Error getting stock from Redis: %v", err)
 		// 如果 Redis 獲取失敗，返回快取值
-		resultChan <- botStatusCache.Stock
-		return
-	}
-
-	stock, err := strconv.Atoi(stockStr)
-	if err != nil {
-		log.Printf("Error parsing stock value from Redis: %v", err)
-		resultChan <- botStatusCache.Stock
+		resultChan <- cached
 		return
 	}
 
-	resultChan <- stock
+	resultChan <- level
 }
 
-func getPrice(resultChan chan<- int) {
+func getPrice(key string, cached int, resultChan chan<- int) {
 	ctx := context.Background()
 
 	// 從 Redis 獲取價格數據
-	priceStr, err := model.RedisClient.Get(ctx, "REDIS_PRICE").Result()
+	priceStr, err := model.RedisClient.Get(ctx, key).Result()
 	if err != nil {
 		log.Printf("Error getting price from Redis: %v", err)
 		// 如果 Redis 獲取失敗，返回快取值
-		resultChan <- botStatusCache.Price
+		resultChan <- cached
 		return
 	}
 
 	price, err := strconv.Atoi(priceStr)
 	if err != nil {
 		log.Printf("Error parsing price value from Redis: %v", err)
-		resultChan <- botStatusCache.Price
+		resultChan <- cached
 		return
 	}
 
 	resultChan <- price
 }
 
-func getOrders(resultChan chan<- int) {
-	var collection = model.Db.Collection("orders")
-	cond := bson.M{
-		"OrderStatus.TradeStatus": "1",
-	}
-	count, err := collection.CountDocuments(context.TODO(), &cond)
+func getPaused(resultChan chan<- bool) {
+	ctx := context.Background()
 
-	condV2 := bson.M{
-		"OrderStatus.Amt": bson.M{
-			"$exists": true,
-		},
+	// 從 Redis 獲取暫停販售狀態
+	paused, err := model.RedisClient.Get(ctx, "REDIS_SALES_PAUSED").Result()
+	if err == redis.Nil {
+		resultChan <- false
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting sales paused from Redis: %v", err)
+		resultChan <- botStatusCache.Paused
+		return
 	}
 
-	countV2, errV2 := model.Db.Collection("orderv2").CountDocuments(context.TODO(), &condV2)
+	resultChan <- paused == "1"
+}
 
-	if err != nil || errV2 != nil {
+func getOrders(productID string, cached int, resultChan chan<- int) {
+	count, err := orders.CountPaid(context.TODO(), productID)
+	if err != nil {
 		log.Println("Error occurred while reading orders:", err)
-		log.Println("Error occurred while reading orderv2:", errV2)
-		resultChan <- botStatusCache.Orders
+		resultChan <- cached
 		return
 	}
-	resultChan <- int(count + countV2)
+	resultChan <- count
 }
 
 func getTransactions(resultChan chan<- int) {
-	ctx := context.TODO()
-
-	userTotal := 0
-	transTotal := 0
-
-	// Step 1: aggregate from "users"
-	aggregation := mongo.Pipeline{
-		bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$Transaction"}}}},
-		bson.D{{Key: "$match", Value: bson.D{{Key: "Transaction.Traded", Value: true}}}},
-		bson.D{{Key: "$group", Value: bson.D{
-			{Key: "_id", Value: nil},
-			{Key: "total", Value: bson.D{{Key: "$sum", Value: "$Transaction.Count"}}},
-		}}},
-	}
-
-	if userCur, err := model.Db.Collection("users").Aggregate(ctx, aggregation); err == nil {
-		defer userCur.Close(ctx)
-		if userCur.Next(ctx) {
-			var res struct {
-				Total int `bson:"total"`
-			}
-			if decodeErr := userCur.Decode(&res); decodeErr != nil {
-				log.Println("Error decoding user aggregation:", decodeErr)
-			} else {
-				userTotal = res.Total
-			}
-		}
-	} else {
-		log.Println("Error aggregating users:", err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	total, err := deliveries.Claimed(ctx, "")
+	if err != nil {
+		log.Println("Error summing deliveries:", err)
 	}
+	resultChan <- total
+}
 
-	// Step 2: sum from "transactions"
-	cond := bson.M{"traded": true}
-	if transCur, err := model.Db.Collection("transcations").Find(ctx, cond); err == nil {
-		defer transCur.Close(ctx)
-		for transCur.Next(ctx) {
-			var t struct {
-				Count int `bson:"Count"`
+// getMarketPrices 查詢所有設定的市場來源，查詢失敗的來源沿用快取值
+func getMarketPrices(items []int, resultChan chan<- []MarketPrice) {
+	sources := steam.MarketSources(items...)
+	prices := make([]MarketPrice, len(sources))
+
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source steam.MarketSource) {
+			defer wg.Done()
+			prices[i] = MarketPrice{
+				ItemNameID: source.ItemNameID,
+				Currency:   source.Currency.Code,
+				Price:      cachedMarketPrice(source),
 			}
-			if decodeErr := transCur.Decode(&t); decodeErr != nil {
-				log.Println("Error decoding transaction:", decodeErr)
-				continue
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			defer cancel()
+			price, err := steam.GetMarketPrice(ctx, source)
+			if err != nil {
+				log.Printf("Error getting market price for item %d in %s: %v", source.ItemNameID, source.Currency.Code, err)
+				return
 			}
-			transTotal += t.Count
-		}
-	} else {
-		log.Println("Error querying transactions:", err)
+			prices[i].Price = price
+		}(i, source)
 	}
+	wg.Wait()
 
-	// Step 3: return both totals summed
-	resultChan <- userTotal + transTotal
+	resultChan <- prices
 }
 
-func getMarketPrice(resultChan chan<- int) {
-	url := "https://steamcommunity.com/market/itemordershistogram?country=TW&language=tchinese&currency=30&item_nameid=1&two_factor=0"
-
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Println("Error occurred while sending request:", err)
-		resultChan <- botStatusCache.MarketPrice // indicate error to the caller
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("Error occurred while reading response body:", err)
-		resultChan <- botStatusCache.MarketPrice // indicate error to the caller
-		return
-	}
-
-	var item MarketItem
-	err = json.Unmarshal(body, &item)
-	if err != nil {
-		log.Println("Error occurred while unmarshaling JSON:", err)
-		resultChan <- botStatusCache.MarketPrice // indicate error to the caller
-		return
-	}
-
-	price, err := strconv.Atoi(item.LowestSellOrder)
-	if err != nil {
-		log.Println("Error:", err)
-		resultChan <- botStatusCache.MarketPrice // indicate error to the caller
-		return
+// cachedMarketPrice 回傳指定市場來源在快取中的價格
+func cachedMarketPrice(source steam.MarketSource) int {
+	for _, price := range botStatusCache.MarketPrices {
+		if price.ItemNameID == source.ItemNameID && price.Currency == source.Currency.Code {
+			return price.Price
+		}
 	}
-
-	resultChan <- price
+	return 0
 }
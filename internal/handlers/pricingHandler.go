@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"yt-api/internal/pricing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPricingDryRunHandler 處理 GET /api/v1/admin/pricing/dry-run?product= 請求，
+// 回傳定價引擎目前會設定的價格與計算過程，不會寫入 Redis
+func GetPricingDryRunHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	product, ok := lookupProduct(c, ctx, c.Query("product"))
+	if !ok {
+		return
+	}
+
+	result, err := pricing.Run(ctx, *product, true)
+	if err != nil {
+		log.Println("Error running pricing dry-run:", err)
+		c.AbortWithStatusJSON(502, gin.H{"error": "pricing unavailable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"yt-api/internal/catalog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProductsHandler 處理 GET /api/v1/products 請求
+func GetProductsHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	products, err := catalog.List(ctx)
+	if err != nil {
+		log.Println("Error listing products:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"products": products,
+	})
+}
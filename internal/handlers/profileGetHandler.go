@@ -1,15 +1,16 @@
 package handlers
 
 import (
+	"context"
+	"log"
 	"net/http"
-	"os"
-	"yt-api/internal/utils"
+	"time"
+
+	"yt-api/internal/profile"
 
 	"github.com/gin-gonic/gin"
 )
 
-var apiKey = os.Getenv("STEAM_API_KEY")
-
 func GetProfileHandler(c *gin.Context) {
 	steamID, exist := c.Get("steamID")
 	if !exist {
@@ -17,15 +18,25 @@ func GetProfileHandler(c *gin.Context) {
 		return
 	}
 
-	profile, err := utils.GetProfileFromSteam(steamID.(string))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	player, err := profile.Get(ctx, steamID.(string))
 	if err != nil {
-		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		// Steam 無法使用時仍回傳基本資料，讓前端維持登入狀態
+		log.Printf("Error getting Steam profile for SteamID %s: %v", steamID, err)
+		c.JSON(http.StatusOK, gin.H{
+			"name":     steamID,
+			"steamId":  steamID,
+			"avatar":   "",
+			"degraded": true,
+		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"name":    profile.Response.Players[0].PersonaName,
+		"name":    player.PersonaName,
 		"steamId": steamID,
-		"avatar":  profile.Response.Players[0].Avatar,
+		"avatar":  player.Avatar,
 	})
 }
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"yt-api/internal/bot"
+	"yt-api/internal/catalog"
+	"yt-api/internal/model"
+	"yt-api/internal/pricing"
+	"yt-api/internal/stock"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errSalesPaused = errors.New("sales paused")
+	errOutOfStock  = errors.New("insufficient stock")
+)
+
+// lookupProduct 依 ID 取得商品，找不到時中止請求
+func lookupProduct(c *gin.Context, ctx context.Context, id string) (*catalog.Product, bool) {
+	product, err := catalog.Get(ctx, id)
+	if err == catalog.ErrProductNotFound {
+		c.AbortWithStatusJSON(404, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		log.Println("Error getting product:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+	return product, true
+}
+
+// checkAvailability 確認目前開放販售且商品庫存足夠
+func checkAvailability(ctx context.Context, product catalog.Product, count int) error {
+	paused, err := model.RedisClient.Get(ctx, "REDIS_SALES_PAUSED").Result()
+	if err == nil && paused == "1" {
+		return errSalesPaused
+	}
+
+	// 機器人停止回報心跳時自動暫停販售
+	statuses, err := bot.List(ctx)
+	if err != nil {
+		return err
+	}
+	if bot.ShouldPause(statuses) {
+		return errSalesPaused
+	}
+
+	// 可售數量需扣除尚未付款訂單的預留
+	level, err := stock.Get(ctx, product)
+	if err != nil {
+		return err
+	}
+	if count > level.Available {
+		return errOutOfStock
+	}
+	return nil
+}
+
+// GetQuoteHandler 處理 GET /api/v1/quote?count=&method=&product= 請求
+func GetQuoteHandler(c *gin.Context) {
+	steamID, exist := c.Get("steamID")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	product, ok := lookupProduct(c, ctx, c.Query("product"))
+	if !ok {
+		return
+	}
+
+	count, err := strconv.Atoi(c.Query("count"))
+	if err != nil || count < product.MinCount || count > product.MaxCount || count <= 0 {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid count"})
+		return
+	}
+
+	if !checkNotBlocked(c, ctx, steamID.(string)) {
+		return
+	}
+
+	if !checkPurchaseLimits(c, ctx, steamID.(string), count) {
+		return
+	}
+
+	if err := checkAvailability(ctx, *product, count); err == errSalesPaused || err == errOutOfStock {
+		c.AbortWithStatusJSON(409, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		log.Println("Error checking availability:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	quote, err := pricing.NewQuote(ctx, steamID.(string), *product, count, c.Query("method"))
+	if err == pricing.ErrInvalidMethod {
+		c.AbortWithStatusJSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("Error creating quote:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
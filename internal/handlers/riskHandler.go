@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/model"
+	"yt-api/internal/orders"
+	"yt-api/internal/risk"
+	"yt-api/internal/stock"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type heldOrderResponse struct {
+	orders.Order
+	Risk *risk.Assessment `json:"Risk"`
+}
+
+type reviewOrderRequest struct {
+	Reason string `json:"reason"`
+}
+
+// GetHeldOrdersHandler 處理 GET /api/v2/admin/orders/held 請求，列出等待風險審核的訂單
+func GetHeldOrdersHandler(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := model.Db.Collection("orderv2").Find(ctx, bson.M{
+		"DeliveryHold": true,
+		"Risk.Status":  risk.StatusPending,
+	}, options.Find().SetSort(bson.D{{Key: "OrderStatus.Data_id", Value: -1}}))
+	if err != nil {
+		log.Println("Error occurred while finding held orders:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var held []orders.V2
+	if err := cursor.All(ctx, &held); err != nil {
+		log.Println("Error occurred while reading held orders:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	response := make([]heldOrderResponse, 0, len(held))
+	for _, order := range held {
+		response = append(response, heldOrderResponse{
+			Order: orders.FromV2(order),
+			Risk:  order.Risk,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orders": response,
+	})
+}
+
+// reviewHeldOrder 更新等待審核訂單的狀態並寫入 audit log
+func reviewHeldOrder(c *gin.Context, status string) {
+	actor, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req reviewOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Reason == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "reason is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	orderID := c.Param("id")
+	set := bson.M{
+		"Risk.Status":     status,
+		"Risk.ReviewedBy": actor,
+		"Risk.ReviewedAt": time.Now(),
+		"DeliveryHold":    status != risk.StatusApproved,
+	}
+	var order orders.V2
+	err := model.Db.Collection("orderv2").FindOneAndUpdate(ctx,
+		bson.M{"OrderStatus.Data_id": orderID, "Risk.Status": risk.StatusPending},
+		bson.M{"$set": set},
+	).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		c.AbortWithStatusJSON(404, gin.H{"error": "held order not found"})
+		return
+	}
+	if err != nil {
+		log.Println("Error reviewing held order:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	model.WriteAudit(ctx, model.AuditEntry{
+		Action:   "order.review",
+		Actor:    actor,
+		Target:   orderID,
+		Reason:   req.Reason,
+		Previous: risk.StatusPending,
+		Value:    status,
+	})
+
+	paid := order.OrderStatus.Amt == order.OrderStatus.Amount
+	switch status {
+	case risk.StatusApproved:
+		// 已付款的訂單核准後才入帳，尚未付款的訂單於付款時入帳
+		if paid {
+			creditOrder(order)
+		}
+	case risk.StatusRejected:
+		returnStock(order)
+		if paid {
+			log.Printf("Order %s rejected after payment, refund required", orderID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"orderId": orderID, "status": status})
+}
+
+// ApproveHeldOrderHandler 處理 POST /api/v2/admin/orders/:id/approve 請求
+func ApproveHeldOrderHandler(c *gin.Context) {
+	reviewHeldOrder(c, risk.StatusApproved)
+}
+
+// RejectHeldOrderHandler 處理 POST /api/v2/admin/orders/:id/reject 請求
+func RejectHeldOrderHandler(c *gin.Context) {
+	reviewHeldOrder(c, risk.StatusRejected)
+}
+
+// returnStock 釋放被拒絕訂單預留的庫存，已付款轉為售出的數量加回實體庫存；
+// 釋放失敗時由 stock.StartReleaser 補上
+func returnStock(order orders.V2) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	orderID := order.OrderStatus.DataID
+	product, err := catalog.Get(ctx, order.ProductID)
+	if err != nil {
+		log.Printf("Error getting product of rejected order %s: %v", orderID, err)
+		return
+	}
+	if _, err := stock.Release(ctx, *product, orderID); err != nil {
+		log.Printf("Error releasing stock reserved by rejected order %s: %v", orderID, err)
+		return
+	}
+	if _, err := stock.Restock(ctx, *product, orderID, order.Count); err != nil {
+		log.Printf("Error restocking rejected order %s: %v", orderID, err)
+		return
+	}
+	InvalidateStatusCache()
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"yt-api/internal/model"
+	"yt-api/internal/steamid"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type setTradeURLRequest struct {
+	TradeURL string `json:"tradeUrl"`
+}
+
+// GetTradeURLHandler 處理 GET /api/v1/user/trade-url 請求
+func GetTradeURLHandler(c *gin.Context) {
+	steamID, exist := c.Get("steamID")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user struct {
+		TradeURL string `bson:"TradeURL"`
+	}
+	err := model.Db.Collection("users").FindOne(ctx, bson.M{"SteamID": steamID}).Decode(&user)
+	if err != nil && err != mongo.ErrNoDocuments {
+		log.Println("Error finding user:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tradeUrl": user.TradeURL})
+}
+
+// PutTradeURLHandler 處理 PUT /api/v1/user/trade-url 請求，
+// 交易網址的 partner 必須與登入的 SteamID 相同，每次變更都會記錄
+func PutTradeURLHandler(c *gin.Context) {
+	steamID, exist := c.Get("steamID")
+	if !exist {
+		c.AbortWithStatusJSON(401, gin.H{"error": "authentication required"})
+		return
+	}
+
+	var req setTradeURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid request body"})
+		return
+	}
+	tradeURL := strings.TrimSpace(req.TradeURL)
+
+	partner, _, err := steamid.ParseTradeURL(tradeURL)
+	if err != nil {
+		c.AbortWithStatusJSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if partner.String() != steamID.(string) {
+		log.Printf("Trade url partner %s does not match SteamID %s", partner, steamID)
+		c.AbortWithStatusJSON(400, gin.H{"error": "trade url does not belong to this account"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var previous struct {
+		TradeURL string `bson:"TradeURL"`
+	}
+	err = model.Db.Collection("users").FindOneAndUpdate(ctx,
+		bson.M{"SteamID": steamID},
+		bson.M{"$set": bson.M{"TradeURL": tradeURL, "TradeURLUpdatedAt": time.Now()}},
+		options.FindOneAndUpdate().SetUpsert(true),
+	).Decode(&previous)
+	if err != nil && err != mongo.ErrNoDocuments {
+		log.Println("Error updating trade url:", err)
+		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+		return
+	}
+
+	if previous.TradeURL != tradeURL {
+		model.WriteAudit(ctx, model.AuditEntry{
+			Action:   "trade_url.set",
+			Actor:    steamID.(string),
+			Target:   steamID.(string),
+			Reason:   "updated by user from " + c.ClientIP(),
+			Previous: previous.TradeURL,
+			Value:    tradeURL,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tradeUrl": tradeURL})
+}
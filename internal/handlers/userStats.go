@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"yt-api/internal/model"
+	"yt-api/internal/profile"
+	"yt-api/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserStats 為寫入 users 的統計，用戶列表以此排序與分頁
+type UserStats struct {
+	TotalSpent int       `bson:"TotalSpent"`
+	LastOrder  string    `bson:"LastOrder"`
+	Unclaimed  int       `bson:"Unclaimed"`
+	UpdatedAt  time.Time `bson:"UpdatedAt"`
+}
+
+var (
+	personaBatch      = utils.GetEnvInt("USER_PERSONA_BATCH", 500)
+	personaRefreshAge = utils.GetEnvDuration("USER_PERSONA_REFRESH_AGE", 24*time.Hour)
+)
+
+// RefreshUserStats 重新計算所有用戶的統計並寫入 users 的 Stats
+func RefreshUserStats(ctx context.Context) error {
+	pipeline := append(userStatsStages(),
+		bson.D{{Key: "$project", Value: bson.M{"Stats": bson.M{
+			"TotalSpent": "$totalSpent",
+			"LastOrder":  "$lastOrder",
+			"Unclaimed":  "$unclaimed",
+			"UpdatedAt":  "$$NOW",
+		}}}},
+		bson.D{{Key: "$merge", Value: bson.M{"into": "users", "on": "_id", "whenMatched": "merge", "whenNotMatched": "discard"}}},
+	)
+	cursor, err := model.Db.Collection("users").Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.Close(ctx)
+}
+
+// refreshPersonaNames 為尚未取得或太久沒更新暱稱的用戶向 Steam 取得暱稱，讓暱稱搜尋涵蓋所有用戶
+func refreshPersonaNames(ctx context.Context) error {
+	cursor, err := model.Db.Collection("users").Find(ctx,
+		bson.M{"$or": bson.A{
+			bson.M{"PersonaUpdatedAt": bson.M{"$exists": false}},
+			bson.M{"PersonaUpdatedAt": bson.M{"$lt": time.Now().Add(-personaRefreshAge)}},
+		}},
+		options.Find().
+			SetSort(bson.D{{Key: "PersonaUpdatedAt", Value: 1}}).
+			SetLimit(int64(personaBatch)).
+			SetProjection(bson.M{"SteamID": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	var users []User
+	if err := cursor.All(ctx, &users); err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	steamIDs := make([]string, 0, len(users))
+	for _, user := range users {
+		steamIDs = append(steamIDs, user.SteamID)
+	}
+	players, err := profile.GetMany(ctx, steamIDs)
+	if err != nil {
+		return err
+	}
+
+	// 找不到資料的帳號也記錄時間，避免每次都重新查詢
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(steamIDs))
+	for _, id := range steamIDs {
+		set := bson.M{"PersonaUpdatedAt": now}
+		if player, ok := players[id]; ok {
+			set["PersonaName"] = player.PersonaName
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"SteamID": id}).
+			SetUpdate(bson.M{"$set": set}))
+	}
+	_, err = model.Db.Collection("users").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// StartUserStatsRefresher 定期更新用戶列表排序用的統計與暱稱，間隔由 USER_STATS_INTERVAL 設定
+func StartUserStatsRefresher() {
+	interval := utils.GetEnvDuration("USER_STATS_INTERVAL", 10*time.Minute)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			if err := RefreshUserStats(ctx); err != nil {
+				log.Println("Error refreshing user stats:", err)
+			}
+			if err := refreshPersonaNames(ctx); err != nil {
+				log.Println("Error refreshing persona names:", err)
+			}
+			cancel()
+		}
+	}()
+}
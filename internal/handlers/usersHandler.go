@@ -2,71 +2,81 @@ package handlers
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"log"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+	"yt-api/internal/balance"
+	"yt-api/internal/deliveries"
 	"yt-api/internal/model"
-	"yt-api/internal/utils"
+	"yt-api/internal/orders"
+	"yt-api/internal/profile"
+	"yt-api/internal/steamid"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// User 表示從 users collection 取得的用戶資料
+// User 表示從 users collection 取得的用戶資料，統計欄位由列表查詢計算，
+// Stats 為 StartUserStatsRefresher 定期寫入的統計，供排序與分頁使用
 type User struct {
-	SteamID string `bson:"SteamID" json:"SteamID"`
+	SteamID       string     `bson:"SteamID" json:"SteamID"`
+	PersonaName   string     `bson:"PersonaName,omitempty" json:"PersonaName,omitempty"`
+	Stats         *UserStats `bson:"Stats,omitempty" json:"-"`
+	TotalSpent    int        `bson:"totalSpent" json:"-"`
+	OrderCount    int        `bson:"orderCount" json:"-"`
+	KeysPurchased int        `bson:"keysPurchased" json:"-"`
+	ClaimedCount  int        `bson:"claimedCount" json:"-"`
+	Unclaimed     int        `bson:"unclaimed" json:"-"`
+	LastOrder     string     `bson:"lastOrder" json:"-"`
 }
 
 // UserResponse 表示 API 回傳的用戶資料格式
 type UserResponse struct {
-	SteamID string `json:"steamId"`
-	Name    string `json:"name"`
+	SteamID        string `json:"steamId"`
+	Name           string `json:"name"`
+	AvatarURL      string `json:"avatarUrl"`
+	TotalSpent     int    `json:"totalSpent"`
+	OrderCount     int    `json:"orderCount"`
+	KeysPurchased  int    `json:"keysPurchased"`
+	ClaimedCount   int    `json:"claimedCount"`
+	UnclaimedCount int    `json:"unclaimedCount"`
+	LastOrder      string `json:"lastOrder,omitempty"`
 }
 
-// UserDetail 表示用戶詳細資料的回應格式
-type UserDetail struct {
-	SteamID         string `json:"steamId"`
-	Name            string `json:"name"`
-	AvatarURL       string `json:"avatarUrl"`
-	UnclaimedCount  int    `json:"unclaimedCount"`
-	ClaimedCount    int    `json:"claimedCount"`
-	CompletedOrders int    `json:"completedOrders"`
-	ActiveOrders    int    `json:"activeOrders"`
+// userListCursor 表示用戶列表的分頁位置，記錄上一頁最後一筆的排序值與 SteamID
+type userListCursor struct {
+	Value   interface{} `json:"v"`
+	SteamID string      `json:"id"`
+}
+
+// userSortFields 對應 sort 參數與 users 中已儲存的統計欄位，排序與分頁不需先計算所有用戶的統計
+var userSortFields = map[string]string{
+	"totalSpent": "Stats.TotalSpent",
+	"lastOrder":  "Stats.LastOrder",
+	"unclaimed":  "Stats.Unclaimed",
 }
 
-// OrderV2 表示從 orderv2 collection 取得的訂單資料
-type OrderV2 struct {
-	SteamID     string `bson:"SteamID" json:"SteamID"`
-	Price       int    `bson:"Price" json:"Price"`
-	Count       int    `bson:"Count" json:"Count"`
-	OrderStatus struct {
-		SmilePayNO string `bson:"SmilePayNO" json:"SmilePayNO"`
-		DataID     string `bson:"Data_id" json:"Data_id"`
-		Amount     int    `bson:"Amount" json:"Amount"`
-		PayEndDate string `bson:"PayEndDate" json:"PayEndDate"`
-		PayMethod  string `bson:"PayMethod" json:"PayMethod"`
-		AtmBankNo  string `bson:"AtmBankNo" json:"AtmBankNo"`
-		AtmNo      string `bson:"AtmNo" json:"AtmNo"`
-		IbonNo     string `bson:"IbonNo" json:"IbonNo"`
-		FamiNO     string `bson:"FamiNO" json:"FamiNO"`
-		// Callback Data
-		ProcessDate string `bson:"Process_date" json:"Process_date"`
-		ProcessTime string `bson:"Process_time" json:"Process_time"`
-		Amt         int    `bson:"Amt" json:"Amt"`
-	} `bson:"OrderStatus" json:"OrderStatus"`
+// UserDetail 表示用戶詳細資料的回應格式
+type UserDetail struct {
+	SteamID   string `json:"steamId"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatarUrl"`
+	KeyBalance
 }
 
-// Transaction 表示從 transactions collection 取得的交易資料
-type Transaction struct {
-	ID        string    `bson:"_id,omitempty" json:"_id,omitempty"`
-	SteamID   string    `bson:"steamID" json:"steamID"`
-	TradeID   string    `bson:"tradeId" json:"tradeId"`
-	Count     int       `bson:"Count" json:"Count"`
-	Traded    bool      `bson:"traded" json:"traded"`
-	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
-	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
-	V         int       `bson:"__v" json:"__v"`
+// KeyBalance 表示用戶的鑰匙領取狀況與訂單統計
+type KeyBalance struct {
+	UnclaimedCount  int `json:"unclaimedCount"`
+	ClaimedCount    int `json:"claimedCount"`
+	CompletedOrders int `json:"completedOrders"`
+	ActiveOrders    int `json:"activeOrders"`
 }
 
 // TransactionResponse 表示交易記錄的回應格式
@@ -77,7 +87,200 @@ type TransactionResponse struct {
 	Timestamp string `json:"timestamp"`
 }
 
-// GetUsersHandler 處理 GET /api/v1/users 請求
+// userStatsStages 計算每個用戶的消費金額、訂單數與已領取/未領取數量
+func userStatsStages() mongo.Pipeline {
+	return mongo.Pipeline{
+		bson.D{{Key: "$lookup", Value: bson.M{"from": "orderv2", "localField": "SteamID", "foreignField": "SteamID", "as": "orders"}}},
+		bson.D{{Key: "$lookup", Value: bson.M{"from": "orders", "localField": "SteamID", "foreignField": "SteamID", "as": "legacyOrders"}}},
+		bson.D{{Key: "$lookup", Value: bson.M{"from": "deliveries", "localField": "SteamID", "foreignField": "SteamID", "as": "trades"}}},
+		bson.D{{Key: "$addFields", Value: bson.M{
+			// 舊訂單轉換為與 orderv2 相同的欄位，訂單編號以 ObjectID 的建立時間表示
+			"legacyOrders": bson.M{"$map": bson.M{
+				"input": "$legacyOrders",
+				"as":    "o",
+				"in": bson.M{
+					"Count": "$$o.Count",
+					// 已轉移至 orderv2 的舊訂單由 orders 計算
+					"paid": bson.M{"$and": bson.A{
+						bson.M{"$eq": bson.A{"$$o.OrderStatus.TradeStatus", "1"}},
+						bson.M{"$ne": bson.A{"$$o.Migrated", true}},
+					}},
+					"OrderStatus": bson.M{
+						"Amount": bson.M{"$cond": bson.A{
+							bson.M{"$gt": bson.A{"$$o.OrderStatus.Amt", 0}},
+							"$$o.OrderStatus.Amt",
+							bson.M{"$multiply": bson.A{"$$o.Price", "$$o.Count"}},
+						}},
+						"Data_id": bson.M{"$dateToString": bson.M{
+							"format":   "%Y%m%d%H%M%S",
+							"date":     bson.M{"$toDate": "$$o._id"},
+							"timezone": "+08:00",
+						}},
+					},
+				},
+			}},
+		}}},
+		bson.D{{Key: "$addFields", Value: bson.M{
+			"paidOrders": bson.M{"$concatArrays": bson.A{
+				bson.M{"$filter": bson.M{
+					"input": "$orders",
+					"as":    "o",
+					"cond":  bson.M{"$eq": bson.A{"$$o.OrderStatus.Amt", "$$o.OrderStatus.Amount"}},
+				}},
+				bson.M{"$filter": bson.M{"input": "$legacyOrders", "as": "o", "cond": "$$o.paid"}},
+			}},
+			"tradedCount": bson.M{"$sum": bson.M{"$map": bson.M{
+				"input": bson.M{"$filter": bson.M{"input": "$trades", "as": "t", "cond": "$$t.Traded"}},
+				"as":    "t",
+				"in":    "$$t.Count",
+			}}},
+			// 轉移後的舊訂單 Data_id 為 TradeNo，時間以舊訂單的 ObjectID 為準
+			"lastOrder": bson.M{"$ifNull": bson.A{bson.M{"$max": bson.M{"$concatArrays": bson.A{
+				bson.M{"$map": bson.M{
+					"input": bson.M{"$filter": bson.M{"input": "$orders", "as": "o", "cond": bson.M{"$ne": bson.A{"$$o.Source", orders.SourceLegacy}}}},
+					"as":    "o",
+					"in":    "$$o.OrderStatus.Data_id",
+				}},
+				"$legacyOrders.OrderStatus.Data_id",
+			}}}, ""}},
+		}}},
+		bson.D{{Key: "$addFields", Value: bson.M{
+			"totalSpent":    bson.M{"$sum": "$paidOrders.OrderStatus.Amount"},
+			"orderCount":    bson.M{"$size": "$paidOrders"},
+			"keysPurchased": bson.M{"$sum": "$paidOrders.Count"},
+			"claimedCount":  "$tradedCount",
+		}}},
+		// 尚未領取數量以鑰匙帳本的餘額為準
+		bson.D{{Key: "$lookup", Value: bson.M{"from": "key_balances", "localField": "SteamID", "foreignField": "SteamID", "as": "balance"}}},
+		bson.D{{Key: "$addFields", Value: bson.M{
+			"unclaimed": bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$balance.Balance", 0}}, 0}},
+		}}},
+		bson.D{{Key: "$project", Value: bson.M{"orders": 0, "legacyOrders": 0, "trades": 0, "paidOrders": 0, "tradedCount": 0, "balance": 0, "Transaction": 0}}},
+	}
+}
+
+// userSearchFilter 依 SteamID、暱稱或繳費代碼建立搜尋條件，
+// 暱稱比對 users 中的 PersonaName，由 StartUserStatsRefresher 定期為所有用戶補上
+func userSearchFilter(ctx context.Context, q string) (bson.M, error) {
+	if id, err := steamid.Parse(q); err == nil {
+		return bson.M{"SteamID": id.String()}, nil
+	}
+
+	// 以繳費代碼或訂單編號找出對應的 SteamID
+	codeFilter := bson.M{"$or": bson.A{
+		bson.M{"OrderStatus.Data_id": q},
+		bson.M{"OrderStatus.SmilePayNO": q},
+		bson.M{"OrderStatus.AtmNo": q},
+		bson.M{"OrderStatus.IbonNo": q},
+		bson.M{"OrderStatus.FamiNO": q},
+	}}
+	steamIDs, err := model.Db.Collection("orderv2").Distinct(ctx, "SteamID", codeFilter)
+	if err != nil {
+		return nil, err
+	}
+	legacyIDs, err := model.Db.Collection("orders").Distinct(ctx, "SteamID", bson.M{"OrderStatus.TradeNo": q})
+	if err != nil {
+		return nil, err
+	}
+	steamIDs = append(steamIDs, legacyIDs...)
+
+	pattern := regexp.QuoteMeta(q)
+	return bson.M{"$or": bson.A{
+		bson.M{"SteamID": bson.M{"$regex": "^" + pattern}},
+		bson.M{"PersonaName": bson.M{"$regex": pattern, "$options": "i"}},
+		bson.M{"SteamID": bson.M{"$in": steamIDs}},
+	}}, nil
+}
+
+// encodeUserCursor 將最後一筆資料已儲存的排序值編碼為下一頁的 cursor，尚未計算統計時排序值為 null
+func encodeUserCursor(sortField string, user User) string {
+	cursor := userListCursor{SteamID: user.SteamID}
+	if user.Stats != nil {
+		switch sortField {
+		case "Stats.TotalSpent":
+			cursor.Value = user.Stats.TotalSpent
+		case "Stats.Unclaimed":
+			cursor.Value = user.Stats.Unclaimed
+		case "Stats.LastOrder":
+			cursor.Value = user.Stats.LastOrder
+		}
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// userCursorFilter 回傳排在 cursor 之後的條件，沒有統計的用戶排序值為 null，遞增時排在最前面、遞減時排在最後面
+func userCursorFilter(sortField string, direction int, cursor *userListCursor) bson.M {
+	after := bson.M{sortField: cursor.Value, "SteamID": bson.M{"$gt": cursor.SteamID}}
+	if cursor.Value == nil {
+		if direction == 1 {
+			return bson.M{"$or": bson.A{after, bson.M{sortField: bson.M{"$ne": nil}}}}
+		}
+		return after
+	}
+	op := "$lt"
+	if direction == 1 {
+		op = "$gt"
+	}
+	or := bson.A{bson.M{sortField: bson.M{op: cursor.Value}}, after}
+	if direction == -1 {
+		or = append(or, bson.M{sortField: nil})
+	}
+	return bson.M{"$or": or}
+}
+
+// decodeUserCursor 解析 cursor 參數
+func decodeUserCursor(raw string) (*userListCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var cursor userListCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// fillPersonaNames 批次向 Steam 取得暱稱與頭像，並把暱稱寫回 users 供搜尋
+func fillPersonaNames(ctx context.Context, users []User, response []UserResponse) {
+	steamIDs := make([]string, 0, len(users))
+	for _, user := range users {
+		steamIDs = append(steamIDs, user.SteamID)
+	}
+	if len(steamIDs) == 0 {
+		return
+	}
+
+	players, err := profile.GetMany(ctx, steamIDs)
+	if err != nil {
+		log.Println("Error getting Steam profiles for user list:", err)
+		return
+	}
+
+	var writes []mongo.WriteModel
+	for i := range response {
+		player, ok := players[response[i].SteamID]
+		if !ok {
+			continue
+		}
+		response[i].Name = player.PersonaName
+		response[i].AvatarURL = player.Avatar
+		if player.PersonaName != users[i].PersonaName {
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"SteamID": player.SteamID}).
+				SetUpdate(bson.M{"$set": bson.M{"PersonaName": player.PersonaName, "PersonaUpdatedAt": time.Now()}}))
+		}
+	}
+
+	if len(writes) > 0 {
+		if _, err := model.Db.Collection("users").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			log.Println("Error saving persona names:", err)
+		}
+	}
+}
+
+// GetUsersHandler 處理 GET /api/v1/users?q=&sort=&order=&cursor=&limit= 請求
 func GetUsersHandler(c *gin.Context) {
 	steamID, exist := c.Get("steamID")
 	if !exist {
@@ -90,12 +293,51 @@ func GetUsersHandler(c *gin.Context) {
 		return
 	}
 
-	collection := model.Db.Collection("users")
+	sortField, ok := userSortFields[c.DefaultQuery("sort", "lastOrder")]
+	if !ok {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid sort"})
+		return
+	}
+	direction := -1
+	if c.Query("order") == "asc" {
+		direction = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid limit"})
+		return
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	cursor, err := collection.Find(ctx, bson.M{})
+	pipeline := mongo.Pipeline{}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		filter, err := userSearchFilter(ctx, q)
+		if err != nil {
+			log.Println("Error building user search:", err)
+			c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+			return
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: filter}})
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := decodeUserCursor(raw)
+		if err != nil {
+			c.AbortWithStatusJSON(400, gin.H{"error": "invalid cursor"})
+			return
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: userCursorFilter(sortField, direction, cursor)}})
+	}
+	// 先依已儲存的統計分頁，只為這一頁的用戶計算即時統計
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: sortField, Value: direction}, {Key: "SteamID", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: limit + 1}},
+	)
+	pipeline = append(pipeline, userStatsStages()...)
+
+	cursor, err := model.Db.Collection("users").Aggregate(ctx, pipeline)
 	if err != nil {
 		log.Println("Error occurred while finding users:", err)
 		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
@@ -110,102 +352,104 @@ func GetUsersHandler(c *gin.Context) {
 		return
 	}
 
-	// 轉換為回應格式，Name 固定為 SteamID
-	var response []UserResponse
+	nextCursor := ""
+	if len(users) > limit {
+		users = users[:limit]
+		nextCursor = encodeUserCursor(sortField, users[limit-1])
+	}
+
+	response := make([]UserResponse, 0, len(users))
 	for _, user := range users {
+		name := user.PersonaName
+		if name == "" {
+			name = user.SteamID
+		}
 		response = append(response, UserResponse{
-			SteamID: user.SteamID,
-			Name:    user.SteamID, // Name 固定回覆 SteamID
+			SteamID:        user.SteamID,
+			Name:           name,
+			TotalSpent:     user.TotalSpent,
+			OrderCount:     user.OrderCount,
+			KeysPurchased:  user.KeysPurchased,
+			ClaimedCount:   user.ClaimedCount,
+			UnclaimedCount: user.Unclaimed,
+			LastOrder:      parseDateTime(user.LastOrder),
 		})
 	}
+	fillPersonaNames(ctx, users, response)
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, gin.H{
+		"users":      response,
+		"nextCursor": nextCursor,
+	})
 }
 
-// getUserOrderStats 根據 SteamID 獲取用戶的訂單統計資料
+// getUserOrderStats 根據 SteamID 統計用戶在 orders 與 orderv2 中的訂單
 func getUserOrderStats(steamID string) (completedOrders, activeOrders, payedAmount int, err error) {
-	collection := model.Db.Collection("orderv2")
-
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// 查詢該用戶的所有訂單
-	cursor, err := collection.Find(ctx, bson.M{"SteamID": steamID})
+	stats, err := orders.GetStats(ctx, steamID)
 	if err != nil {
 		log.Printf("Error finding orders for SteamID %s: %v", steamID, err)
 		return 0, 0, 0, err
 	}
-	defer cursor.Close(ctx)
-
-	var orders []OrderV2
-	if err = cursor.All(ctx, &orders); err != nil {
-		log.Printf("Error decoding orders for SteamID %s: %v", steamID, err)
-		return 0, 0, 0, err
-	}
-
-	// 統計訂單狀態
-	for _, order := range orders {
-		// 判斷訂單是否已完成 (有 ProcessDate 和 ProcessTime 表示已付款完成)
-		payEndDate, err := time.Parse("2006/01/02 15:04:05", order.OrderStatus.PayEndDate)
-		if err != nil {
-			log.Println("Error parsing PayEndDate:", err)
-			payEndDate = time.Time{}
-		}
-		if order.OrderStatus.Amt == order.OrderStatus.Amount {
-			completedOrders++
-			payedAmount += order.Count
-		} else if payEndDate.After(time.Now()) {
-			activeOrders++
-		}
-	}
-
-	return completedOrders, activeOrders, payedAmount, nil
+	return stats.CompletedOrders, stats.ActiveOrders, stats.PaidKeys, nil
 }
 
 func getUserTradedAmount(steamID string) (tradedAmount int, err error) {
-	collection := model.Db.Collection("transcations")
-
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	cursor, err := collection.Find(ctx, bson.M{"steamID": steamID, "traded": true})
+
+	count, err := deliveries.Claimed(ctx, steamID)
 	if err != nil {
-		log.Printf("Error counting traded transactions for SteamID %s: %v", steamID, err)
+		log.Printf("Error counting traded deliveries for SteamID %s: %v", steamID, err)
 		return 0, err
 	}
+	return count, nil
+}
 
-	var transactions []Transaction
-	if err = cursor.All(ctx, &transactions); err != nil {
-		log.Printf("Error decoding transactions for SteamID %s: %v", steamID, err)
-		return 0, err
+// getUserBalance 由鑰匙帳本取得尚未領取的數量，並附上已領取數量與訂單統計
+func getUserBalance(steamID string) (KeyBalance, error) {
+	// 獲取訂單統計資料
+	completedOrders, activeOrders, _, err := getUserOrderStats(steamID)
+	if err != nil {
+		log.Printf("Error getting order stats for SteamID %s: %v", steamID, err)
+		return KeyBalance{}, err
 	}
-	count := 0
-	for _, txn := range transactions {
-		count += txn.Count
+
+	// 獲取交易成功的次數
+	tradedAmount, err := getUserTradedAmount(steamID)
+	if err != nil {
+		log.Printf("Error getting traded amount for SteamID %s: %v", steamID, err)
+		return KeyBalance{}, err
 	}
 
-	return count, nil
+	// 尚未記帳的購買與出貨由 balance.StartReconcile 補上
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	unclaimed, err := balance.Get(ctx, steamID)
+	if err != nil {
+		log.Printf("Error getting key balance for SteamID %s: %v", steamID, err)
+		return KeyBalance{}, err
+	}
+
+	return KeyBalance{
+		UnclaimedCount:  unclaimed,
+		ClaimedCount:    tradedAmount,
+		CompletedOrders: completedOrders,
+		ActiveOrders:    activeOrders,
+	}, nil
 }
 
 // getUserTransactions 根據 SteamID 獲取用戶的交易記錄
 func getUserTransactions(steamID string) ([]TransactionResponse, error) {
-	collection := model.Db.Collection("transcations")
-
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// 查詢該用戶的所有交易記錄，按時間倒序排列
-	cursor, err := collection.Find(ctx, bson.M{"steamID": steamID}, &options.FindOptions{
-		Sort: bson.D{{Key: "_id", Value: -1}},
-	})
+	// 查詢該用戶的所有出貨紀錄，按時間倒序排列
+	transactions, err := deliveries.List(ctx, steamID)
 	if err != nil {
-		log.Printf("Error finding transactions for SteamID %s: %v", steamID, err)
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var transactions []Transaction
-	if err = cursor.All(ctx, &transactions); err != nil {
-		log.Printf("Error decoding transactions for SteamID %s: %v", steamID, err)
+		log.Printf("Error finding deliveries for SteamID %s: %v", steamID, err)
 		return nil, err
 	}
 
@@ -221,8 +465,8 @@ func getUserTransactions(steamID string) ([]TransactionResponse, error) {
 		timestamp := txn.CreatedAt.Format("2006-01-02 15:04")
 
 		response = append(response, TransactionResponse{
-			ID:        txn.TradeID, // 使用 tradeId 作為交易 ID
-			Quantity:  txn.Count,   // 使用 Count 作為數量
+			ID:        txn.TradeID,
+			Quantity:  txn.Count,
 			Status:    status,
 			Timestamp: timestamp,
 		})
@@ -244,49 +488,37 @@ func GetUserDetailHandler(c *gin.Context) {
 		return
 	}
 
-	targetId := c.Param("id")
-	if targetId == "" {
-		c.AbortWithStatusJSON(400, gin.H{"error": "steam id is required"})
-		return
-	}
-
-	// 獲取訂單統計資料
-	completedOrders, activeOrders, payAmount, err := getUserOrderStats(targetId)
-	if err != nil {
-		log.Printf("Error getting order stats for SteamID %s: %v", targetId, err)
-		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+	targetId, ok := resolveSteamIDParam(c, c.Param("id"))
+	if !ok {
 		return
 	}
 
-	// 獲取交易成功的次數
-	tradedAmount, err := getUserTradedAmount(targetId)
+	balance, err := getUserBalance(targetId)
 	if err != nil {
-		log.Printf("Error getting traded amount for SteamID %s: %v", targetId, err)
 		c.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
 		return
 	}
 
 	// 獲取 Steam 用戶資料
-	profile, err := utils.GetProfileFromSteam(targetId)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	player, err := profile.Get(ctx, targetId)
 	var name, avatarURL string
 	if err != nil {
 		log.Printf("Error getting Steam profile for SteamID %s: %v", targetId, err)
 		// 如果無法獲取 Steam 資料，使用預設值
 		name = targetId
 		avatarURL = ""
-	} else if len(profile.Response.Players) > 0 {
-		name = profile.Response.Players[0].PersonaName
-		avatarURL = profile.Response.Players[0].AvatarFull
+	} else {
+		name = player.PersonaName
+		avatarURL = player.AvatarFull
 	}
 
 	userDetail := UserDetail{
-		SteamID:         targetId,
-		Name:            name,
-		AvatarURL:       avatarURL,
-		UnclaimedCount:  payAmount - tradedAmount,
-		ClaimedCount:    tradedAmount,
-		CompletedOrders: completedOrders,
-		ActiveOrders:    activeOrders,
+		SteamID:    targetId,
+		Name:       name,
+		AvatarURL:  avatarURL,
+		KeyBalance: balance,
 	}
 
 	c.JSON(http.StatusOK, userDetail)
@@ -305,9 +537,8 @@ func GetUserTransactionsHandler(c *gin.Context) {
 		return
 	}
 
-	targetId := c.Param("id")
-	if targetId == "" {
-		c.AbortWithStatusJSON(400, gin.H{"error": "steam id is required"})
+	targetId, ok := resolveSteamIDParam(c, c.Param("id"))
+	if !ok {
 		return
 	}
 
@@ -0,0 +1,271 @@
+package inventory
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"yt-api/internal/bot"
+	"yt-api/internal/catalog"
+	"yt-api/internal/model"
+	"yt-api/internal/steam"
+	"yt-api/internal/types"
+	"yt-api/internal/utils"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const snapshotCollection = "inventory_snapshots"
+
+var (
+	appID     = utils.GetEnvInt("INVENTORY_APP_ID", 440)
+	contextID = utils.GetEnv("INVENTORY_CONTEXT_ID", "2")
+	// snapshotKeep 為每台機器人保留的快照數量，預設為每 10 分鐘同步時一天的份量
+	snapshotKeep = utils.GetEnvInt("INVENTORY_SNAPSHOT_KEEP", 144)
+)
+
+// Asset 為快照中一項可交易且屬於上架商品的物品
+type Asset struct {
+	AssetID        string `bson:"AssetID" json:"assetId"`
+	ClassID        string `bson:"ClassID" json:"classId"`
+	InstanceID     string `bson:"InstanceID" json:"instanceId"`
+	MarketHashName string `bson:"MarketHashName" json:"marketHashName"`
+	ProductID      string `bson:"ProductID" json:"productId"`
+	Amount         int    `bson:"Amount" json:"amount"`
+}
+
+// Snapshot 為 inventory_snapshots 中一次同步的結果，Stock 由 Assets 依商品加總
+type Snapshot struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BotID      string             `bson:"BotID" json:"botId"`
+	SteamID    string             `bson:"SteamID" json:"steamId"`
+	TotalItems int                `bson:"TotalItems" json:"totalItems"`
+	Assets     []Asset            `bson:"Assets" json:"assets,omitempty"`
+	Stock      map[string]int     `bson:"Stock" json:"stock"`
+	CreatedAt  time.Time          `bson:"CreatedAt" json:"createdAt"`
+}
+
+// Mismatch 表示由快照算出的庫存與 Redis 中的庫存不一致
+type Mismatch struct {
+	ProductID string `json:"productId"`
+	Derived   int    `json:"derived"`
+	Redis     int    `json:"redis"`
+}
+
+// Report 為所有機器人最新快照加總後與 Redis 庫存的比對結果
+type Report struct {
+	Snapshots  []Snapshot     `json:"snapshots"`
+	Stock      map[string]int `json:"stock"`
+	Mismatches []Mismatch     `json:"mismatches"`
+}
+
+// Sync 取得機器人的 Steam 庫存，篩選出可交易的上架商品並寫入一份快照
+func Sync(ctx context.Context, botID, steamID string) (*Snapshot, error) {
+	products, err := catalog.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	productByName := make(map[string]string, len(products))
+	for _, p := range products {
+		if p.MarketHashName != "" {
+			productByName[p.MarketHashName] = p.ID
+		}
+	}
+
+	inv, err := steam.GetInventory(ctx, steamID, appID, contextID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := buildSnapshot(botID, steamID, inv, productByName)
+	if _, err := model.Db.Collection(snapshotCollection).InsertOne(ctx, snapshot); err != nil {
+		return nil, err
+	}
+	if err := prune(ctx, botID); err != nil {
+		log.Printf("Error pruning inventory snapshots of %s: %v", botID, err)
+	}
+	return snapshot, nil
+}
+
+// prune 刪除機器人較舊的快照，只保留最新的 snapshotKeep 份；
+// 不使用 TTL 索引，停用的機器人仍保留最後一份快照
+func prune(ctx context.Context, botID string) error {
+	if snapshotKeep <= 0 {
+		return nil
+	}
+	var oldest Snapshot
+	err := model.Db.Collection(snapshotCollection).FindOne(ctx,
+		bson.M{"BotID": botID},
+		options.FindOne().
+			SetSort(bson.D{{Key: "CreatedAt", Value: -1}}).
+			SetSkip(int64(snapshotKeep-1)).
+			SetProjection(bson.M{"CreatedAt": 1}),
+	).Decode(&oldest)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = model.Db.Collection(snapshotCollection).DeleteMany(ctx, bson.M{
+		"BotID":     botID,
+		"CreatedAt": bson.M{"$lt": oldest.CreatedAt},
+	})
+	return err
+}
+
+// buildSnapshot 篩選出可交易且 market_hash_name 對應到上架商品的物品，並依商品加總數量
+func buildSnapshot(botID, steamID string, inv *types.Inventory, productByName map[string]string) *Snapshot {
+	type descriptionKey struct{ classID, instanceID string }
+	tradable := make(map[descriptionKey]string)
+	for _, d := range inv.Descriptions {
+		if d.Tradable == 1 {
+			tradable[descriptionKey{d.Classid, d.Instanceid}] = d.Market_hash_name
+		}
+	}
+
+	snapshot := &Snapshot{
+		ID:         primitive.NewObjectID(),
+		BotID:      botID,
+		SteamID:    steamID,
+		TotalItems: inv.Total_inventory_count,
+		Assets:     []Asset{},
+		Stock:      make(map[string]int),
+		CreatedAt:  time.Now(),
+	}
+	for _, a := range inv.Assets {
+		name, ok := tradable[descriptionKey{a.Classid, a.Instanceid}]
+		if !ok {
+			continue
+		}
+		productID, ok := productByName[name]
+		if !ok {
+			continue
+		}
+		amount, err := strconv.Atoi(a.Amount)
+		if err != nil || amount <= 0 {
+			amount = 1
+		}
+		snapshot.Assets = append(snapshot.Assets, Asset{
+			AssetID:        a.Assetid,
+			ClassID:        a.Classid,
+			InstanceID:     a.Instanceid,
+			MarketHashName: name,
+			ProductID:      productID,
+			Amount:         amount,
+		})
+		snapshot.Stock[productID] += amount
+	}
+	return snapshot
+}
+
+// SyncAll 同步所有啟用中且有 SteamID 的機器人，並比對加總的庫存與 Redis；
+// 單一機器人同步失敗時沿用它的上一份快照
+func SyncAll(ctx context.Context) (*Report, error) {
+	statuses, err := bot.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range statuses {
+		if !s.Enabled || s.SteamID == "" {
+			continue
+		}
+		if _, err := Sync(ctx, s.BotID, s.SteamID); err != nil {
+			log.Printf("Error syncing inventory of bot %s: %v", s.BotID, err)
+		}
+	}
+	return Check(ctx)
+}
+
+// Check 加總所有啟用中機器人的最新快照，與各商品 StockKey 中的庫存比對
+func Check(ctx context.Context) (*Report, error) {
+	statuses, err := bot.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Snapshots: []Snapshot{}, Stock: make(map[string]int), Mismatches: []Mismatch{}}
+	for _, s := range statuses {
+		if !s.Enabled || s.SteamID == "" {
+			continue
+		}
+		snapshot, err := Latest(ctx, s.BotID)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for productID, stock := range snapshot.Stock {
+			report.Stock[productID] += stock
+		}
+		// 報告只列出數量，明細以 Latest 查詢
+		snapshot.Assets = nil
+		report.Snapshots = append(report.Snapshots, *snapshot)
+	}
+	if len(report.Snapshots) == 0 {
+		return report, nil
+	}
+
+	products, err := catalog.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range products {
+		if p.MarketHashName == "" {
+			continue
+		}
+		stock := 0
+		value, err := model.RedisClient.Get(ctx, p.StockKey).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		if err == nil {
+			stock, _ = strconv.Atoi(value)
+		}
+		if derived := report.Stock[p.ID]; derived != stock {
+			report.Mismatches = append(report.Mismatches, Mismatch{ProductID: p.ID, Derived: derived, Redis: stock})
+		}
+	}
+	return report, nil
+}
+
+// Latest 回傳機器人最新的快照
+func Latest(ctx context.Context, botID string) (*Snapshot, error) {
+	var snapshot Snapshot
+	err := model.Db.Collection(snapshotCollection).FindOne(ctx,
+		bson.M{"BotID": botID},
+		options.FindOne().SetSort(bson.D{{Key: "CreatedAt", Value: -1}}),
+	).Decode(&snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// StartSync 定期同步機器人庫存並記錄不一致，間隔由 INVENTORY_SYNC_INTERVAL 設定
+func StartSync() {
+	interval := utils.GetEnvDuration("INVENTORY_SYNC_INTERVAL", 10*time.Minute)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			report, err := SyncAll(ctx)
+			cancel()
+			if err != nil {
+				log.Println("Error syncing bot inventories:", err)
+				continue
+			}
+			for _, m := range report.Mismatches {
+				log.Printf("Inventory mismatch for %s: %d in bot inventories, %d in Redis", m.ProductID, m.Derived, m.Redis)
+			}
+		}
+	}()
+}
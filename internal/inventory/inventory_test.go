@@ -0,0 +1,62 @@
+package inventory
+
+import (
+	"encoding/json"
+	"testing"
+
+	"yt-api/internal/types"
+)
+
+func TestBuildSnapshot(t *testing.T) {
+	const raw = `{
+		"total_inventory_count": 6,
+		"assets": [
+			{"assetid": "1", "classid": "10", "instanceid": "0", "amount": "1"},
+			{"assetid": "2", "classid": "10", "instanceid": "0", "amount": "1"},
+			{"assetid": "3", "classid": "20", "instanceid": "0", "amount": "3"},
+			{"assetid": "4", "classid": "30", "instanceid": "0", "amount": "1"},
+			{"assetid": "5", "classid": "40", "instanceid": "0", "amount": "1"},
+			{"assetid": "6", "classid": "10", "instanceid": "7", "amount": "x"}
+		],
+		"descriptions": [
+			{"classid": "10", "instanceid": "0", "tradable": 1, "market_hash_name": "Mann Co. Supply Crate Key"},
+			{"classid": "10", "instanceid": "7", "tradable": 1, "market_hash_name": "Mann Co. Supply Crate Key"},
+			{"classid": "20", "instanceid": "0", "tradable": 1, "market_hash_name": "Refined Metal"},
+			{"classid": "30", "instanceid": "0", "tradable": 0, "market_hash_name": "Mann Co. Supply Crate Key"},
+			{"classid": "40", "instanceid": "0", "tradable": 1, "market_hash_name": "Unlisted Item"}
+		]
+	}`
+	var inv types.Inventory
+	if err := json.Unmarshal([]byte(raw), &inv); err != nil {
+		t.Fatal(err)
+	}
+	productByName := map[string]string{
+		"Mann Co. Supply Crate Key": "tf2-key",
+		"Refined Metal":             "tf2-ref",
+	}
+
+	snapshot := buildSnapshot("bot-1", "76561198000000000", &inv, productByName)
+
+	tests := []struct {
+		productID string
+		want      int
+	}{
+		// 不可交易與未上架的物品不計入，數量無法解析時以 1 計算
+		{"tf2-key", 3},
+		{"tf2-ref", 3},
+	}
+	for _, tt := range tests {
+		if got := snapshot.Stock[tt.productID]; got != tt.want {
+			t.Errorf("Stock[%s] = %d, want %d", tt.productID, got, tt.want)
+		}
+	}
+	if len(snapshot.Stock) != len(tests) {
+		t.Errorf("Stock = %v, want only listed products", snapshot.Stock)
+	}
+	if len(snapshot.Assets) != 4 {
+		t.Errorf("len(Assets) = %d, want 4", len(snapshot.Assets))
+	}
+	if snapshot.BotID != "bot-1" || snapshot.TotalItems != 6 {
+		t.Errorf("snapshot = %+v", snapshot)
+	}
+}
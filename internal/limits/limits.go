@@ -0,0 +1,240 @@
+package limits
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"yt-api/internal/model"
+	"yt-api/internal/orders"
+	"yt-api/internal/utils"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrOrderInProgress 表示同一用戶已有訂單正在建立
+var ErrOrderInProgress = errors.New("another order is being created")
+
+// lockTTL 為建立訂單鎖定的期限，須長於建立訂單的逾時
+var lockTTL = utils.GetEnvDuration("ORDER_LOCK_TTL", 30*time.Second)
+
+// 超過限制時回傳的錯誤代碼
+const (
+	CodeOpenUnpaid = "LIMIT_OPEN_UNPAID"
+	CodeDaily      = "LIMIT_KEYS_PER_DAY"
+	CodeMonthly    = "LIMIT_KEYS_30_DAYS"
+	CodeFirstOrder = "LIMIT_FIRST_ORDER"
+)
+
+// Limits 表示一位用戶的購買限制，0 表示不限制
+type Limits struct {
+	MaxOpenUnpaid int `bson:"MaxOpenUnpaid" json:"maxOpenUnpaid"`
+	MaxKeysPerDay int `bson:"MaxKeysPerDay" json:"maxKeysPerDay"`
+	MaxKeys30Days int `bson:"MaxKeys30Days" json:"maxKeys30Days"`
+	MaxFirstOrder int `bson:"MaxFirstOrder" json:"maxFirstOrder"`
+}
+
+// Overrides 為管理員針對單一用戶設定的限制，nil 表示沿用預設值
+type Overrides struct {
+	MaxOpenUnpaid *int `bson:"MaxOpenUnpaid,omitempty" json:"maxOpenUnpaid"`
+	MaxKeysPerDay *int `bson:"MaxKeysPerDay,omitempty" json:"maxKeysPerDay"`
+	MaxKeys30Days *int `bson:"MaxKeys30Days,omitempty" json:"maxKeys30Days"`
+	MaxFirstOrder *int `bson:"MaxFirstOrder,omitempty" json:"maxFirstOrder"`
+}
+
+// Error 表示超過購買限制
+type Error struct {
+	Code    string
+	Limit   int
+	Current int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: limit %d, current %d", e.Code, e.Limit, e.Current)
+}
+
+// Defaults 回傳由環境變數設定的預設限制
+func Defaults() Limits {
+	return Limits{
+		MaxOpenUnpaid: utils.GetEnvInt("LIMIT_OPEN_UNPAID", 3),
+		MaxKeysPerDay: utils.GetEnvInt("LIMIT_KEYS_PER_DAY", 100),
+		MaxKeys30Days: utils.GetEnvInt("LIMIT_KEYS_30_DAYS", 500),
+		MaxFirstOrder: utils.GetEnvInt("LIMIT_FIRST_ORDER", 10),
+	}
+}
+
+// apply 以管理員設定覆寫預設限制
+func (l Limits) apply(o Overrides) Limits {
+	if o.MaxOpenUnpaid != nil {
+		l.MaxOpenUnpaid = *o.MaxOpenUnpaid
+	}
+	if o.MaxKeysPerDay != nil {
+		l.MaxKeysPerDay = *o.MaxKeysPerDay
+	}
+	if o.MaxKeys30Days != nil {
+		l.MaxKeys30Days = *o.MaxKeys30Days
+	}
+	if o.MaxFirstOrder != nil {
+		l.MaxFirstOrder = *o.MaxFirstOrder
+	}
+	return l
+}
+
+// GetOverrides 讀取 users 中的 LimitOverrides
+func GetOverrides(ctx context.Context, steamID string) (Overrides, error) {
+	var user struct {
+		LimitOverrides Overrides `bson:"LimitOverrides"`
+	}
+	err := model.Db.Collection("users").FindOne(ctx, bson.M{"SteamID": steamID}).Decode(&user)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return Overrides{}, err
+	}
+	return user.LimitOverrides, nil
+}
+
+// Effective 回傳用戶實際適用的限制
+func Effective(ctx context.Context, steamID string) (Limits, error) {
+	overrides, err := GetOverrides(ctx, steamID)
+	if err != nil {
+		return Limits{}, err
+	}
+	return Defaults().apply(overrides), nil
+}
+
+// Check 確認用戶建立一筆 count 個的新訂單不會超過限制，超過時回傳 *Error；
+// 訂單由 orders 與 orderv2 兩個 collection 讀取，尚未轉移的舊訂單也會計入
+func Check(ctx context.Context, steamID string, count int) error {
+	limits, err := Effective(ctx, steamID)
+	if err != nil {
+		return err
+	}
+	list, err := orders.Find(ctx, steamID)
+	if err != nil {
+		return err
+	}
+	return evaluate(limits, list, count, time.Now())
+}
+
+// evaluate 依用戶現有的訂單檢查新訂單是否超過限制
+func evaluate(limits Limits, list []orders.Order, count int, now time.Time) error {
+	if limits.MaxOpenUnpaid > 0 {
+		open := 0
+		for _, o := range list {
+			// 已取消、已過期與審核未通過的訂單不會是 StatusUnpaid
+			if o.Status == orders.StatusUnpaid {
+				open++
+			}
+		}
+		if open >= limits.MaxOpenUnpaid {
+			return &Error{Code: CodeOpenUnpaid, Limit: limits.MaxOpenUnpaid, Current: open}
+		}
+	}
+
+	if limits.MaxFirstOrder > 0 && count > limits.MaxFirstOrder && !hasPaid(list) {
+		return &Error{Code: CodeFirstOrder, Limit: limits.MaxFirstOrder, Current: count}
+	}
+
+	windows := []struct {
+		code  string
+		limit int
+		since time.Time
+	}{
+		{CodeDaily, limits.MaxKeysPerDay, now.Add(-24 * time.Hour)},
+		{CodeMonthly, limits.MaxKeys30Days, now.Add(-30 * 24 * time.Hour)},
+	}
+	for _, w := range windows {
+		if w.limit <= 0 {
+			continue
+		}
+		keys := keysSince(list, w.since)
+		if keys+count > w.limit {
+			return &Error{Code: w.code, Limit: w.limit, Current: keys}
+		}
+	}
+	return nil
+}
+
+// hasPaid 回傳用戶是否有已付款的訂單
+func hasPaid(list []orders.Order) bool {
+	for _, o := range list {
+		if o.Paid() {
+			return true
+		}
+	}
+	return false
+}
+
+// keysSince 統計 since 之後建立、已付款或尚未過期且未取消的訂單數量
+func keysSince(list []orders.Order, since time.Time) int {
+	total := 0
+	for _, o := range list {
+		if o.CreatedAt.Before(since) {
+			continue
+		}
+		if o.Paid() || o.Status == orders.StatusUnpaid {
+			total += o.Count
+		}
+	}
+	return total
+}
+
+// Lock 為建立訂單期間對用戶的鎖定，確保限制檢查到訂單寫入之間沒有其他訂單建立
+type Lock struct {
+	steamID string
+	token   string
+}
+
+// unlockScript 只在鎖定仍屬於自己時解除
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Acquire 鎖定用戶建立訂單，同一用戶已有訂單正在建立時回傳 ErrOrderInProgress
+func Acquire(ctx context.Context, steamID string) (*Lock, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	lock := &Lock{steamID: steamID, token: hex.EncodeToString(token)}
+	ok, err := model.RedisClient.SetNX(ctx, "ORDER_LOCK:"+steamID, lock.token, lockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrOrderInProgress
+	}
+	return lock, nil
+}
+
+// Release 解除鎖定
+func (l *Lock) Release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := unlockScript.Run(ctx, model.RedisClient, []string{"ORDER_LOCK:" + l.steamID}, l.token).Err(); err != nil {
+		log.Printf("Error releasing order lock of %s: %v", l.steamID, err)
+	}
+}
+
+// SetOverrides 儲存管理員設定的限制並回傳設定前的值
+func SetOverrides(ctx context.Context, steamID string, overrides Overrides) (Overrides, error) {
+	previous, err := GetOverrides(ctx, steamID)
+	if err != nil {
+		return Overrides{}, err
+	}
+	_, err = model.Db.Collection("users").UpdateOne(ctx,
+		bson.M{"SteamID": steamID},
+		bson.M{"$set": bson.M{"LimitOverrides": overrides}},
+		options.Update().SetUpsert(true),
+	)
+	return previous, err
+}
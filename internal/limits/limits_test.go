@@ -0,0 +1,64 @@
+package limits
+
+import (
+	"testing"
+	"time"
+
+	"yt-api/internal/orders"
+)
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)
+	order := func(status orders.Status, count int, age time.Duration) orders.Order {
+		return orders.Order{Status: status, Count: count, CreatedAt: now.Add(-age)}
+	}
+	limits := Limits{MaxOpenUnpaid: 2, MaxKeysPerDay: 10, MaxKeys30Days: 50, MaxFirstOrder: 5}
+
+	tests := []struct {
+		name     string
+		list     []orders.Order
+		count    int
+		wantCode string
+		current  int
+	}{
+		{"no orders", nil, 5, "", 0},
+		{"first order too large", nil, 6, CodeFirstOrder, 6},
+		{"legacy paid order lifts first order limit", []orders.Order{{Source: orders.SourceLegacy, Status: orders.StatusPaid, Count: 1, CreatedAt: now.Add(-40 * 24 * time.Hour)}}, 6, "", 0},
+		{"held order lifts first order limit", []orders.Order{order(orders.StatusHeld, 1, 40*24*time.Hour)}, 6, "", 0},
+		{"rejected order does not lift first order limit", []orders.Order{order(orders.StatusRejected, 1, 40*24*time.Hour)}, 6, CodeFirstOrder, 6},
+		{"open unpaid orders", []orders.Order{order(orders.StatusUnpaid, 1, time.Hour), order(orders.StatusUnpaid, 1, time.Hour)}, 1, CodeOpenUnpaid, 2},
+		{"cancelled and expired orders are not open", []orders.Order{order(orders.StatusCancelled, 1, time.Hour), order(orders.StatusExpired, 1, time.Hour)}, 1, "", 0},
+		{"daily limit reached", []orders.Order{order(orders.StatusPaid, 8, time.Hour)}, 3, CodeDaily, 8},
+		{"daily limit exactly", []orders.Order{order(orders.StatusPaid, 8, time.Hour)}, 2, "", 0},
+		{"daily window counts unpaid orders", []orders.Order{order(orders.StatusPaid, 5, time.Hour), order(orders.StatusUnpaid, 4, time.Minute)}, 2, CodeDaily, 9},
+		{"daily window ignores cancelled orders", []orders.Order{order(orders.StatusPaid, 5, time.Hour), order(orders.StatusCancelled, 5, time.Minute)}, 2, "", 0},
+		{"order outside daily window", []orders.Order{order(orders.StatusPaid, 10, 25*time.Hour)}, 5, "", 0},
+		{"monthly limit", []orders.Order{order(orders.StatusPaid, 45, 2*24*time.Hour)}, 6, CodeMonthly, 45},
+		{"order outside monthly window", []orders.Order{order(orders.StatusPaid, 45, 31*24*time.Hour)}, 6, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := evaluate(limits, tt.list, tt.count, now)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("evaluate() error = %v, want nil", err)
+				}
+				return
+			}
+			limitErr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("evaluate() error = %v, want %s", err, tt.wantCode)
+			}
+			if limitErr.Code != tt.wantCode || limitErr.Current != tt.current {
+				t.Errorf("evaluate() = %s current %d, want %s current %d", limitErr.Code, limitErr.Current, tt.wantCode, tt.current)
+			}
+		})
+	}
+
+	t.Run("zero disables limits", func(t *testing.T) {
+		list := []orders.Order{order(orders.StatusUnpaid, 100, time.Hour)}
+		if err := evaluate(Limits{}, list, 1000, now); err != nil {
+			t.Errorf("evaluate() error = %v, want nil", err)
+		}
+	})
+}
@@ -12,6 +12,9 @@ import (
 
 var jwtKey = []byte(os.Getenv("JWT_SECRET"))
 
+// AuthMiddleware 驗證 session cookie 中的 JWT 並設定 steamID；
+// 刻意不查詢 blocklist，避免每個請求都查詢資料庫：被封鎖的帳號在登入時不會取得 session，
+// 已取得的 session 仍可查看資料，報價、建立訂單與出貨則由 handler 檢查封鎖
 func AuthMiddleware(c *gin.Context) {
 	// 直接從 cookie 讀取 JWT token
 	tokenString, err := c.Cookie("session")
@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var botToken = os.Getenv("BOT_API_TOKEN")
+
+// BotMiddleware 驗證機器人呼叫時帶的 Authorization: Bearer <BOT_API_TOKEN>，
+// 並以 X-Bot-ID 標示是哪一台機器人
+func BotMiddleware(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if botToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(botToken)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid bot token"})
+		return
+	}
+
+	botID := c.GetHeader("X-Bot-ID")
+	if botID == "" {
+		botID = "default"
+	}
+	c.Set("botID", botID)
+
+	c.Next()
+}
@@ -0,0 +1,304 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 已發布的 migration 不呼叫其他 package 的函式，該 package 日後修改時舊版本的結果才不會跟著改變；
+// 這裡的欄位與來源鍵為版本 6 與 8 發布時 deliveries 與 balance 的格式
+
+// duplicateSteamIDs 回傳 users 中重複或缺少的 SteamID 與對應的文件 ID
+func duplicateSteamIDs(ctx context.Context, db *mongo.Database) (map[string][]primitive.ObjectID, error) {
+	cursor, err := db.Collection("users").Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$group", Value: bson.M{"_id": "$SteamID", "ids": bson.M{"$push": "$_id"}, "count": bson.M{"$sum": 1}}}},
+		bson.D{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	duplicates := make(map[string][]primitive.ObjectID)
+	for cursor.Next(ctx) {
+		var row struct {
+			SteamID interface{}          `bson:"_id"`
+			IDs     []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+		steamID, _ := row.SteamID.(string)
+		duplicates[steamID] = row.IDs
+	}
+	return duplicates, cursor.Err()
+}
+
+// duplicatesError 列出重複的 SteamID，需由管理員合併後再執行 migration
+func duplicatesError(duplicates map[string][]primitive.ObjectID) error {
+	if len(duplicates) == 0 {
+		return nil
+	}
+	var lines []string
+	for steamID, ids := range duplicates {
+		if steamID == "" {
+			steamID = "(missing)"
+		}
+		hex := make([]string, 0, len(ids))
+		for _, id := range ids {
+			hex = append(hex, id.Hex())
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", steamID, strings.Join(hex, ", ")))
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		log.Println("Duplicate users SteamID", line)
+	}
+	return fmt.Errorf("%d duplicate users SteamID values must be merged before creating the unique index", len(duplicates))
+}
+
+// backfillDeliveries 將 transcations 與 users.Transaction 寫入 deliveries，已寫入的紀錄不會覆寫
+func backfillDeliveries(ctx context.Context, db *mongo.Database) error {
+	now := time.Now()
+	collection := db.Collection("deliveries")
+	insert := func(key string, doc bson.M) error {
+		doc["SourceKey"] = key
+		doc["UpdatedAt"] = now
+		_, err := collection.UpdateOne(ctx, bson.M{"SourceKey": key},
+			bson.M{"$setOnInsert": doc}, options.Update().SetUpsert(true))
+		return err
+	}
+
+	cursor, err := db.Collection("transcations").Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var t struct {
+			ID        primitive.ObjectID `bson:"_id"`
+			SteamID   string             `bson:"steamID"`
+			TradeID   string             `bson:"tradeId"`
+			RequestID string             `bson:"requestId"`
+			Count     int                `bson:"Count"`
+			Traded    bool               `bson:"traded"`
+			CreatedAt time.Time          `bson:"createdAt"`
+		}
+		if err := cursor.Decode(&t); err != nil {
+			return err
+		}
+		if t.CreatedAt.IsZero() {
+			t.CreatedAt = t.ID.Timestamp()
+		}
+		doc := bson.M{
+			"SteamID":   t.SteamID,
+			"TradeID":   t.TradeID,
+			"Count":     t.Count,
+			"Traded":    t.Traded,
+			"Source":    "transcations",
+			"CreatedAt": t.CreatedAt,
+		}
+		if t.RequestID != "" {
+			doc["RequestID"] = t.RequestID
+		}
+		if err := insert("transcations:"+t.ID.Hex(), doc); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	users, err := db.Collection("users").Find(ctx, bson.M{"Transaction.0": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"SteamID": 1, "Transaction": 1}))
+	if err != nil {
+		return err
+	}
+	defer users.Close(ctx)
+	for users.Next(ctx) {
+		var user struct {
+			ID          primitive.ObjectID `bson:"_id"`
+			SteamID     string             `bson:"SteamID"`
+			Transaction bson.RawValue      `bson:"Transaction"`
+		}
+		if err := users.Decode(&user); err != nil {
+			return err
+		}
+		var transactions []struct {
+			TradeID string `bson:"TradeID"`
+			Count   int    `bson:"Count"`
+			Traded  bool   `bson:"Traded"`
+		}
+		if err := user.Transaction.Unmarshal(&transactions); err != nil {
+			return err
+		}
+		for i, t := range transactions {
+			err := insert(fmt.Sprintf("users:%s:%d", user.SteamID, i), bson.M{
+				"SteamID":   user.SteamID,
+				"TradeID":   t.TradeID,
+				"Count":     t.Count,
+				"Traded":    t.Traded,
+				"Source":    "users",
+				"CreatedAt": user.ID.Timestamp(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		// 與 deliveries.Sync 相同，記錄已同步的內容讓同步略過這位用戶
+		if _, err := db.Collection("users").UpdateByID(ctx, user.ID, bson.M{"$set": bson.M{"DeliveriesSynced": user.Transaction}}); err != nil {
+			return err
+		}
+	}
+	return users.Err()
+}
+
+// ledgerTransfer 為回填帳本的一筆交易，Amount 為用戶帳戶的入帳金額
+type ledgerTransfer struct {
+	SteamID      string
+	Type         string
+	Counterparty string
+	Amount       int
+	Ref          string
+}
+
+// backfillLedger 為已付款的訂單寫入購買入帳，為不是由出貨請求產生的已交易紀錄寫入出貨扣帳，
+// 已記帳的 Ref 由唯一索引略過，寫入後依分錄重新計算 key_balances
+func backfillLedger(ctx context.Context, db *mongo.Database) error {
+	var transfers []ledgerTransfer
+
+	var legacy []struct {
+		SteamID     string `bson:"SteamID"`
+		Count       int    `bson:"Count"`
+		OrderStatus struct {
+			TradeNo string `bson:"TradeNo"`
+		} `bson:"OrderStatus"`
+	}
+	if err := findAll(ctx, db.Collection("orders"), bson.M{
+		"OrderStatus.TradeStatus": "1",
+		"Migrated":                bson.M{"$ne": true},
+		"Count":                   bson.M{"$gt": 0},
+	}, &legacy); err != nil {
+		return err
+	}
+	for _, o := range legacy {
+		transfers = append(transfers, ledgerTransfer{SteamID: o.SteamID, Type: "purchase_credit", Counterparty: "system:sales", Amount: o.Count, Ref: "order:" + o.OrderStatus.TradeNo})
+	}
+
+	var v2 []struct {
+		SteamID     string `bson:"SteamID"`
+		Count       int    `bson:"Count"`
+		OrderStatus struct {
+			DataID string `bson:"Data_id"`
+		} `bson:"OrderStatus"`
+	}
+	if err := findAll(ctx, db.Collection("orderv2"), bson.M{
+		"$expr":        bson.M{"$eq": bson.A{"$OrderStatus.Amt", "$OrderStatus.Amount"}},
+		"DeliveryHold": bson.M{"$ne": true},
+		"Risk.Status":  bson.M{"$ne": "rejected"},
+		"Count":        bson.M{"$gt": 0},
+	}, &v2); err != nil {
+		return err
+	}
+	for _, o := range v2 {
+		transfers = append(transfers, ledgerTransfer{SteamID: o.SteamID, Type: "purchase_credit", Counterparty: "system:sales", Amount: o.Count, Ref: "order:" + o.OrderStatus.DataID})
+	}
+
+	var delivered []struct {
+		ID      primitive.ObjectID `bson:"_id"`
+		SteamID string             `bson:"SteamID"`
+		Count   int                `bson:"Count"`
+	}
+	if err := findAll(ctx, db.Collection("deliveries"), bson.M{
+		"Traded":    true,
+		"RequestID": bson.M{"$in": bson.A{nil, ""}},
+		"Count":     bson.M{"$gt": 0},
+	}, &delivered); err != nil {
+		return err
+	}
+	for _, d := range delivered {
+		transfers = append(transfers, ledgerTransfer{SteamID: d.SteamID, Type: "delivery_debit", Counterparty: "system:deliveries", Amount: -d.Count, Ref: "delivery:" + d.ID.Hex()})
+	}
+
+	session, err := db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	posted := 0
+	for _, t := range transfers {
+		entries := ledgerEntries(t, time.Now())
+		_, err := session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+			return db.Collection("key_ledger").InsertMany(sc, entries)
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		posted++
+	}
+	log.Printf("Backfilled %d of %d ledger transfers", posted, len(transfers))
+
+	// 餘額一律由分錄加總，與 balance.Check 的計算方式相同
+	_, err = db.Collection("key_ledger").Aggregate(ctx, mongo.Pipeline{
+		bson.D{{Key: "$group", Value: bson.M{
+			"_id":       "$Account",
+			"Balance":   bson.M{"$sum": "$Amount"},
+			"SteamID":   bson.M{"$max": "$SteamID"},
+			"UpdatedAt": bson.M{"$max": "$CreatedAt"},
+		}}},
+		// 系統帳戶沒有 SteamID
+		bson.D{{Key: "$set", Value: bson.M{"SteamID": bson.M{"$ifNull": bson.A{"$SteamID", "$$REMOVE"}}}}},
+		bson.D{{Key: "$merge", Value: bson.M{"into": "key_balances", "whenMatched": "merge", "whenNotMatched": "insert"}}},
+	})
+	return err
+}
+
+// ledgerEntries 回傳一筆交易在用戶與系統帳戶的分錄，金額相加為 0
+func ledgerEntries(t ledgerTransfer, now time.Time) []interface{} {
+	txID := primitive.NewObjectID()
+	return []interface{}{
+		bson.M{
+			"_id":       primitive.NewObjectID(),
+			"TxID":      txID,
+			"Account":   "user:" + t.SteamID,
+			"SteamID":   t.SteamID,
+			"Type":      t.Type,
+			"Amount":    t.Amount,
+			"Ref":       t.Ref,
+			"Actor":     "migration",
+			"CreatedAt": now,
+		},
+		bson.M{
+			"_id":       primitive.NewObjectID(),
+			"TxID":      txID,
+			"Account":   t.Counterparty,
+			"Type":      t.Type,
+			"Amount":    -t.Amount,
+			"Ref":       t.Ref,
+			"Actor":     "migration",
+			"CreatedAt": now,
+		},
+	}
+}
+
+// findAll 查詢 collection 並解碼所有結果
+func findAll(ctx context.Context, collection *mongo.Collection, filter bson.M, results interface{}) error {
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, results)
+}
@@ -0,0 +1,227 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"yt-api/internal/model"
+	"yt-api/internal/utils"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	collectionName = "schema_migrations"
+	lockKey        = "SCHEMA_MIGRATIONS_LOCK"
+)
+
+var (
+	ErrLocked       = errors.New("schema migrations are locked by another process")
+	ErrIrreversible = errors.New("migration cannot be reverted")
+)
+
+// Migration 為一個版本的 schema 變更，Down 為 nil 表示無法還原
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// Record 為 schema_migrations 中已套用的版本
+type Record struct {
+	Version   int       `bson:"_id" json:"version"`
+	Name      string    `bson:"Name" json:"name"`
+	AppliedAt time.Time `bson:"AppliedAt" json:"appliedAt"`
+}
+
+// PendingError 表示仍有尚未套用的版本
+type PendingError struct {
+	Pending []Migration
+}
+
+func (e *PendingError) Error() string {
+	return fmt.Sprintf("%d schema migrations pending, first is %d %s", len(e.Pending), e.Pending[0].Version, e.Pending[0].Name)
+}
+
+var lockTTL = utils.GetEnvDuration("MIGRATION_LOCK_TTL", 10*time.Minute)
+
+// releaseScript 只在 lock 仍屬於自己時刪除，避免刪除逾時後被其他程序取得的 lock
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// All 回傳依版本排序的所有 migration
+func All() []Migration {
+	sorted := append([]Migration(nil), versions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return sorted
+}
+
+// Applied 讀取已套用的版本
+func Applied(ctx context.Context) (map[int]Record, error) {
+	cursor, err := model.Db.Collection(collectionName).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []Record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]Record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// Pending 回傳尚未套用的 migration
+func Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pending(All(), applied), nil
+}
+
+// pending 回傳 all 中尚未套用的 migration
+func pending(all []Migration, applied map[int]Record) []Migration {
+	var result []Migration
+	for _, m := range all {
+		if _, ok := applied[m.Version]; !ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// upTo 回傳 pending 中版本不大於 target 的 migration，target 為 0 表示全部
+func upTo(pending []Migration, target int) []Migration {
+	if target <= 0 {
+		return pending
+	}
+	var result []Migration
+	for _, m := range pending {
+		if m.Version > target {
+			break
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+// toRevert 由新到舊回傳要還原的 steps 個已套用的 migration，遇到無法還原的版本時回傳 ErrIrreversible 與之前的版本
+func toRevert(all []Migration, applied map[int]Record, steps int) ([]Migration, error) {
+	var result []Migration
+	for i := len(all) - 1; i >= 0 && len(result) < steps; i-- {
+		m := all[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == nil {
+			return result, fmt.Errorf("migration %d %s: %w", m.Version, m.Name, ErrIrreversible)
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// CheckPending 在有尚未套用的 migration 時回傳 *PendingError，API 啟動前呼叫
+func CheckPending(ctx context.Context) error {
+	pending, err := Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return &PendingError{Pending: pending}
+	}
+	return nil
+}
+
+// Up 依序套用尚未套用且版本不大於 target 的 migration，target 為 0 表示全部套用
+func Up(ctx context.Context, target int) ([]Migration, error) {
+	release, err := lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	pending, err := Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range upTo(pending, target) {
+		log.Printf("Applying migration %d %s", m.Version, m.Name)
+		if err := m.Up(ctx, model.Db); err != nil {
+			return done, fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+		record := Record{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}
+		if _, err := model.Db.Collection(collectionName).InsertOne(ctx, record); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Down 由新到舊還原 steps 個已套用的 migration
+func Down(ctx context.Context, steps int) ([]Migration, error) {
+	release, err := lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	applied, err := Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 遇到無法還原的版本時仍還原之前的版本，再回傳錯誤
+	revert, revertErr := toRevert(All(), applied, steps)
+	var done []Migration
+	for _, m := range revert {
+		log.Printf("Reverting migration %d %s", m.Version, m.Name)
+		if err := m.Down(ctx, model.Db); err != nil {
+			return done, fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+		if _, err := model.Db.Collection(collectionName).DeleteOne(ctx, bson.M{"_id": m.Version}); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, revertErr
+}
+
+// lock 取得 Redis lock，同一時間只允許一個程序執行 migration
+func lock(ctx context.Context) (func(), error) {
+	hostname, _ := os.Hostname()
+	token := fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano())
+
+	ok, err := model.RedisClient.SetNX(ctx, lockKey, token, lockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLocked
+	}
+	return func() {
+		if err := releaseScript.Run(context.Background(), model.RedisClient, []string{lockKey}, token).Err(); err != nil {
+			log.Println("Error releasing migration lock:", err)
+		}
+	}, nil
+}
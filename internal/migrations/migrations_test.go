@@ -0,0 +1,262 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"yt-api/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestVersions(t *testing.T) {
+	// 版本號需遞增，已發布的版本不可修改
+	for i, m := range versions {
+		if m.Name == "" || m.Up == nil {
+			t.Errorf("migration %d missing name or Up", m.Version)
+		}
+		if i > 0 && m.Version <= versions[i-1].Version {
+			t.Errorf("migration %d listed after %d", m.Version, versions[i-1].Version)
+		}
+	}
+	if all := All(); len(all) != len(versions) || all[0].Version != 1 {
+		t.Errorf("All() returned %d migrations starting at %d", len(all), all[0].Version)
+	}
+}
+
+// testMigrations 建立只記錄呼叫順序的 migration，irreversible 中的版本沒有 Down
+func testMigrations(calls *[]string, irreversible ...int) []Migration {
+	var list []Migration
+	for _, version := range []int{3, 1, 2} {
+		version := version
+		m := Migration{
+			Version: version,
+			Name:    fmt.Sprintf("test %d", version),
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				*calls = append(*calls, fmt.Sprintf("up %d", version))
+				return nil
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				*calls = append(*calls, fmt.Sprintf("down %d", version))
+				return nil
+			},
+		}
+		for _, v := range irreversible {
+			if v == version {
+				m.Down = nil
+			}
+		}
+		list = append(list, m)
+	}
+	return list
+}
+
+func versionsOf(list []Migration) []int {
+	result := []int{}
+	for _, m := range list {
+		result = append(result, m.Version)
+	}
+	return result
+}
+
+func TestPendingUpTo(t *testing.T) {
+	defer func(v []Migration) { versions = v }(versions)
+	versions = testMigrations(new([]string))
+	all := All()
+
+	tests := []struct {
+		name    string
+		applied []int
+		target  int
+		want    []int
+	}{
+		{"nothing applied", nil, 0, []int{1, 2, 3}},
+		{"up to target", nil, 2, []int{1, 2}},
+		{"target below pending", []int{1, 2}, 1, []int{}},
+		{"gap is applied in order", []int{2}, 0, []int{1, 3}},
+		{"all applied", []int{1, 2, 3}, 0, []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := map[int]Record{}
+			for _, v := range tt.applied {
+				applied[v] = Record{Version: v}
+			}
+			if got := versionsOf(upTo(pending(all, applied), tt.target)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("upTo(pending()) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToRevert(t *testing.T) {
+	defer func(v []Migration) { versions = v }(versions)
+	applied := map[int]Record{1: {Version: 1}, 2: {Version: 2}, 3: {Version: 3}}
+
+	versions = testMigrations(new([]string))
+	if got, err := toRevert(All(), applied, 2); err != nil || !reflect.DeepEqual(versionsOf(got), []int{3, 2}) {
+		t.Errorf("toRevert(2) = %v, %v, want [3 2]", versionsOf(got), err)
+	}
+	if got, err := toRevert(All(), map[int]Record{1: {Version: 1}, 3: {Version: 3}}, 5); err != nil || !reflect.DeepEqual(versionsOf(got), []int{3, 1}) {
+		t.Errorf("toRevert() skipping unapplied = %v, %v, want [3 1]", versionsOf(got), err)
+	}
+
+	versions = testMigrations(new([]string), 2)
+	got, err := toRevert(All(), applied, 3)
+	if !errors.Is(err, ErrIrreversible) || !reflect.DeepEqual(versionsOf(got), []int{3}) {
+		t.Errorf("toRevert() past irreversible = %v, %v, want [3], ErrIrreversible", versionsOf(got), err)
+	}
+}
+
+func TestDuplicatesError(t *testing.T) {
+	if err := duplicatesError(nil); err != nil {
+		t.Errorf("duplicatesError(nil) = %v", err)
+	}
+	err := duplicatesError(map[string][]primitive.ObjectID{
+		"76561198000000001": {primitive.NewObjectID(), primitive.NewObjectID()},
+		"":                  {primitive.NewObjectID(), primitive.NewObjectID()},
+	})
+	if err == nil || !strings.Contains(err.Error(), "2 duplicate") {
+		t.Errorf("duplicatesError() = %v, want 2 duplicates", err)
+	}
+}
+
+func TestLedgerEntries(t *testing.T) {
+	entries := ledgerEntries(ledgerTransfer{SteamID: "76561198000000001", Type: "delivery_debit", Counterparty: "system:deliveries", Amount: -3, Ref: "delivery:1"}, time.Now())
+	if len(entries) != 2 {
+		t.Fatalf("ledgerEntries() returned %d entries", len(entries))
+	}
+	user, system := entries[0].(bson.M), entries[1].(bson.M)
+	if user["Account"] != "user:76561198000000001" || system["Account"] != "system:deliveries" {
+		t.Errorf("accounts = %v, %v", user["Account"], system["Account"])
+	}
+	if user["Amount"].(int)+system["Amount"].(int) != 0 || user["TxID"] != system["TxID"] {
+		t.Errorf("entries not balanced: %v, %v", user, system)
+	}
+	if _, ok := system["SteamID"]; ok {
+		t.Error("system entry has SteamID")
+	}
+}
+
+// useTestRedis 連接 REDIS_TEST_URL，未設定時略過測試
+func useTestRedis(t *testing.T) {
+	t.Helper()
+	redisURL := os.Getenv("REDIS_TEST_URL")
+	if redisURL == "" {
+		t.Skip("REDIS_TEST_URL not set")
+	}
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := model.RedisClient
+	model.RedisClient = redis.NewClient(opt)
+	t.Cleanup(func() {
+		model.RedisClient.Close()
+		model.RedisClient = previous
+	})
+}
+
+func TestLock(t *testing.T) {
+	useTestRedis(t)
+	ctx := context.Background()
+	model.RedisClient.Del(ctx, lockKey)
+
+	release, err := lock(ctx)
+	if err != nil {
+		t.Fatalf("lock() error = %v", err)
+	}
+	if _, err := lock(ctx); err != ErrLocked {
+		t.Errorf("lock() while locked error = %v, want ErrLocked", err)
+	}
+	release()
+
+	// 逾時後被其他程序取得的 lock 不會被刪除
+	release, err = lock(ctx)
+	if err != nil {
+		t.Fatalf("lock() after release error = %v", err)
+	}
+	model.RedisClient.Set(ctx, lockKey, "other", time.Minute)
+	release()
+	if got, _ := model.RedisClient.Get(ctx, lockKey).Result(); got != "other" {
+		t.Errorf("lock owned by another process = %q after release", got)
+	}
+	model.RedisClient.Del(ctx, lockKey)
+}
+
+// useTestMongo 連接 MONGO_TEST_URL 並使用暫時的資料庫，未設定時略過測試
+func useTestMongo(t *testing.T) {
+	t.Helper()
+	mongoURL := os.Getenv("MONGO_TEST_URL")
+	if mongoURL == "" {
+		t.Skip("MONGO_TEST_URL not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := model.Db
+	model.Db = client.Database(fmt.Sprintf("migrations_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		model.Db.Drop(ctx)
+		client.Disconnect(ctx)
+		model.Db = previous
+	})
+}
+
+func TestUpDown(t *testing.T) {
+	useTestRedis(t)
+	useTestMongo(t)
+	ctx := context.Background()
+	model.RedisClient.Del(ctx, lockKey)
+
+	defer func(v []Migration) { versions = v }(versions)
+	var calls []string
+	versions = testMigrations(&calls, 1)
+
+	if done, err := Up(ctx, 2); err != nil || !reflect.DeepEqual(versionsOf(done), []int{1, 2}) {
+		t.Fatalf("Up(2) = %v, %v", versionsOf(done), err)
+	}
+	if err := CheckPending(ctx); err == nil {
+		t.Error("CheckPending() = nil with version 3 pending")
+	}
+	if done, err := Up(ctx, 0); err != nil || !reflect.DeepEqual(versionsOf(done), []int{3}) {
+		t.Fatalf("Up(0) = %v, %v", versionsOf(done), err)
+	}
+	if err := CheckPending(ctx); err != nil {
+		t.Errorf("CheckPending() = %v after applying all", err)
+	}
+
+	done, err := Down(ctx, 3)
+	if !errors.Is(err, ErrIrreversible) || !reflect.DeepEqual(versionsOf(done), []int{3, 2}) {
+		t.Errorf("Down(3) = %v, %v, want [3 2], ErrIrreversible", versionsOf(done), err)
+	}
+	want := []string{"up 1", "up 2", "up 3", "down 3", "down 2"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if applied, err := Applied(ctx); err != nil || len(applied) != 1 {
+		t.Errorf("Applied() = %v, %v, want only version 1", applied, err)
+	}
+
+	// 其他程序執行中時不套用
+	model.RedisClient.Set(ctx, lockKey, "other", time.Minute)
+	defer model.RedisClient.Del(ctx, lockKey)
+	if _, err := Up(ctx, 0); err != ErrLocked {
+		t.Errorf("Up() while locked error = %v, want ErrLocked", err)
+	}
+}
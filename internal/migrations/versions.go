@@ -0,0 +1,219 @@
+package migrations
+
+import (
+	"context"
+
+	"yt-api/internal/catalog"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// versions 為所有 schema migration，新增時版本號需遞增且不可修改已發布的版本
+var versions = []Migration{
+	{
+		Version: 1,
+		Name:    "orderv2 indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("orderv2").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "OrderStatus.Data_id", Value: 1}}, Options: options.Index().SetName("data_id").SetUnique(true)},
+				{Keys: bson.D{{Key: "SteamID", Value: 1}}, Options: options.Index().SetName("steam_id")},
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("orderv2"), "data_id", "steam_id")
+		},
+	},
+	{
+		Version: 2,
+		Name:    "users SteamID unique index",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// 重複的用戶需由管理員合併，列出後中止，避免建立索引失敗時看不出是哪些用戶
+			duplicates, err := duplicateSteamIDs(ctx, db)
+			if err != nil {
+				return err
+			}
+			if err := duplicatesError(duplicates); err != nil {
+				return err
+			}
+			_, err = db.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "SteamID", Value: 1}},
+				Options: options.Index().SetName("steam_id").SetUnique(true),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("users"), "steam_id")
+		},
+	},
+	{
+		Version: 3,
+		Name:    "transcations steamID index",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("transcations").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "steamID", Value: 1}, {Key: "traded", Value: 1}},
+				Options: options.Index().SetName("steam_id_traded"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("transcations"), "steam_id_traded")
+		},
+	},
+	{
+		// 舊訂單沒有 ProductID，補上後無法分辨原本是否有值，因此不提供 Down
+		Version: 4,
+		Name:    "orderv2 ProductID backfill",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("orderv2").UpdateMany(ctx,
+				bson.M{"ProductID": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"ProductID": catalog.DefaultProductID}},
+			)
+			return err
+		},
+	},
+	{
+		Version: 5,
+		Name:    "deliveries indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("deliveries").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "SourceKey", Value: 1}}, Options: options.Index().SetName("source_key").SetUnique(true)},
+				{Keys: bson.D{{Key: "SteamID", Value: 1}, {Key: "Traded", Value: 1}}, Options: options.Index().SetName("steam_id_traded")},
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("deliveries"), "source_key", "steam_id_traded")
+		},
+	},
+	{
+		// 由 users.Transaction 與 transcations 回填 deliveries，切換期間由 deliveries.StartSync 持續同步
+		Version: 6,
+		Name:    "deliveries backfill",
+		Up:      backfillDeliveries,
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("deliveries").DeleteMany(ctx, bson.M{"Source": bson.M{"$in": bson.A{"transcations", "users"}}})
+			return err
+		},
+	},
+	{
+		Version: 7,
+		Name:    "key ledger indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("key_ledger").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys: bson.D{{Key: "Account", Value: 1}, {Key: "Type", Value: 1}, {Key: "Ref", Value: 1}},
+					Options: options.Index().SetName("account_type_ref").SetUnique(true).
+						SetPartialFilterExpression(bson.M{"Ref": bson.M{"$type": "string"}}),
+				},
+				{Keys: bson.D{{Key: "Account", Value: 1}, {Key: "CreatedAt", Value: -1}}, Options: options.Index().SetName("account_created")},
+				{Keys: bson.D{{Key: "TxID", Value: 1}}, Options: options.Index().SetName("tx_id")},
+			})
+			if err != nil {
+				return err
+			}
+			_, err = db.Collection("key_balances").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "SteamID", Value: 1}},
+				Options: options.Index().SetName("steam_id").SetSparse(true),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if err := dropIndexes(ctx, db.Collection("key_ledger"), "account_type_ref", "account_created", "tx_id"); err != nil {
+				return err
+			}
+			return dropIndexes(ctx, db.Collection("key_balances"), "steam_id")
+		},
+	},
+	{
+		// 由已付款訂單與出貨紀錄建立初始分錄，帳本只新增不修改，因此不提供 Down
+		Version: 8,
+		Name:    "key ledger backfill",
+		Up:      backfillLedger,
+	},
+	{
+		Version: 9,
+		Name:    "delivery_requests indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("delivery_requests").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "SteamID", Value: 1}, {Key: "CreatedAt", Value: -1}},
+				Options: options.Index().SetName("steam_id_created"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("delivery_requests"), "steam_id_created")
+		},
+	},
+	{
+		Version: 10,
+		Name:    "inventory_snapshots indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("inventory_snapshots").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "BotID", Value: 1}, {Key: "CreatedAt", Value: -1}},
+				Options: options.Index().SetName("bot_created"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("inventory_snapshots"), "bot_created")
+		},
+	},
+	{
+		Version: 11,
+		Name:    "users list indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "Stats.TotalSpent", Value: 1}, {Key: "SteamID", Value: 1}}, Options: options.Index().SetName("stats_total_spent")},
+				{Keys: bson.D{{Key: "Stats.LastOrder", Value: 1}, {Key: "SteamID", Value: 1}}, Options: options.Index().SetName("stats_last_order")},
+				{Keys: bson.D{{Key: "Stats.Unclaimed", Value: 1}, {Key: "SteamID", Value: 1}}, Options: options.Index().SetName("stats_unclaimed")},
+				{Keys: bson.D{{Key: "PersonaUpdatedAt", Value: 1}}, Options: options.Index().SetName("persona_updated")},
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("users"), "stats_total_spent", "stats_last_order", "stats_unclaimed", "persona_updated")
+		},
+	},
+	{
+		Version: 12,
+		Name:    "orderv2 LegacyID unique index",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("orderv2").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: bson.D{{Key: "LegacyID", Value: 1}},
+				Options: options.Index().SetName("legacy_id").SetUnique(true).
+					SetPartialFilterExpression(bson.M{"LegacyID": bson.M{"$exists": true}}),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("orderv2"), "legacy_id")
+		},
+	},
+	{
+		Version: 13,
+		Name:    "transcations updatedAt index",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("transcations").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "updatedAt", Value: 1}},
+				Options: options.Index().SetName("updated_at"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			return dropIndexes(ctx, db.Collection("transcations"), "updated_at")
+		},
+	},
+}
+
+// dropIndexes 依名稱刪除索引
+func dropIndexes(ctx context.Context, collection *mongo.Collection, names ...string) error {
+	for _, name := range names {
+		if _, err := collection.Indexes().DropOne(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// AuditEntry 表示一筆寫入 audit_logs collection 的變更紀錄
+type AuditEntry struct {
+	Action    string      `bson:"action" json:"action"`
+	Actor     string      `bson:"actor" json:"actor"`
+	Target    string      `bson:"target,omitempty" json:"target,omitempty"`
+	Reason    string      `bson:"reason" json:"reason"`
+	Previous  interface{} `bson:"previous" json:"previous"`
+	Value     interface{} `bson:"value" json:"value"`
+	CreatedAt time.Time   `bson:"createdAt" json:"createdAt"`
+}
+
+// WriteAudit 寫入一筆變更紀錄
+func WriteAudit(ctx context.Context, entry AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if _, err := Db.Collection("audit_logs").InsertOne(ctx, entry); err != nil {
+		log.Printf("Error writing audit log %s by %s: %v", entry.Action, entry.Actor, err)
+		return err
+	}
+	return nil
+}
@@ -15,7 +15,8 @@ import (
 var mgoClient *mongo.Client
 var Db *mongo.Database
 
-func init() {
+// InitDB 連接 MongoDB，需在使用 Db 之前呼叫
+func InitDB() {
 
 	connectionString := os.Getenv("MONGO_CONNECTION_STRING")
 	serverAPIOptions := options.ServerAPI(options.ServerAPIVersion1)
@@ -0,0 +1,285 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// legacyCheckpointID 為 migration_checkpoints 中記錄轉移進度的文件 ID
+const legacyCheckpointID = "legacy-orders"
+
+// ErrMissingTradeNo 表示舊訂單沒有 TradeNo，無法保留原本的訂單編號
+var ErrMissingTradeNo = errors.New("legacy order has no TradeNo")
+
+// MigrateOptions 為舊訂單轉移的設定
+type MigrateOptions struct {
+	DryRun    bool
+	BatchSize int
+	// Resume 為 true 時從上次記錄的 checkpoint 之後繼續
+	Resume bool
+}
+
+// MigrateResult 為舊訂單轉移的統計
+type MigrateResult struct {
+	Scanned   int
+	Inserted  int
+	Existing  int
+	Conflicts []string
+	// Skipped 為沒有 TradeNo 而未轉移的舊訂單，需人工處理
+	Skipped []string
+	LastID  primitive.ObjectID
+}
+
+// Verification 為轉移後的數量與金額比對結果
+type Verification struct {
+	LegacyPaid     int
+	LegacyTotal    int
+	MigratedPaid   int
+	MigratedTotal  int
+	Unmigrated     int
+	ExpectedOrders int
+	ReportedOrders int
+}
+
+// OK 回傳轉移後的數量與金額是否一致
+func (v Verification) OK() bool {
+	return v.Unmigrated == 0 &&
+		v.LegacyPaid == v.MigratedPaid &&
+		v.LegacyTotal == v.MigratedTotal &&
+		v.ExpectedOrders == v.ReportedOrders
+}
+
+// LegacyToV2 將舊訂單轉換為 orderv2 格式，Data_id 沿用 TradeNo 讓訂單編號保持不變，
+// 沒有 TradeNo 時回傳 ErrMissingTradeNo
+func LegacyToV2(l Legacy) (V2, error) {
+	if l.OrderStatus.TradeNo == "" {
+		return V2{}, ErrMissingTradeNo
+	}
+	v := V2{
+		SteamID:   l.SteamID,
+		ProductID: catalog.DefaultProductID,
+		Price:     l.Price,
+		Count:     l.Count,
+		CreatedAt: l.ID.Timestamp(),
+		Source:    SourceLegacy,
+		LegacyID:  l.ID,
+	}
+	v.OrderStatus.DataID = l.OrderStatus.TradeNo
+	v.OrderStatus.Amount = legacyAmount(l)
+	v.OrderStatus.PayMethod = l.OrderStatus.PaymentType
+	v.OrderStatus.PayInfo = l.OrderStatus.PayInfo
+
+	// 舊訂單的期限只有日期時，補上當天結束時間
+	v.OrderStatus.PayEndDate = l.OrderStatus.ExpireDate
+	if _, err := time.Parse("2006/01/02", l.OrderStatus.ExpireDate); err == nil {
+		v.OrderStatus.PayEndDate = l.OrderStatus.ExpireDate + " 23:59:59"
+	}
+
+	if l.OrderStatus.TradeStatus == "1" {
+		v.OrderStatus.Amt = v.OrderStatus.Amount
+		date, clock, _ := strings.Cut(l.OrderStatus.PayTime, " ")
+		v.OrderStatus.ProcessDate = date
+		v.OrderStatus.ProcessTime = clock
+	}
+	return v, nil
+}
+
+// MigrateLegacy 以 _id 順序分批將舊 orders 轉移至 orderv2，已轉移的訂單不會重複寫入，
+// 中斷後重新執行時會補上寫入 orderv2 但尚未標記的訂單
+func MigrateLegacy(ctx context.Context, opts MigrateOptions) (MigrateResult, error) {
+	var result MigrateResult
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+
+	if opts.Resume {
+		lastID, err := loadCheckpoint(ctx)
+		if err != nil {
+			return result, err
+		}
+		result.LastID = lastID
+		if !lastID.IsZero() {
+			log.Println("Resuming legacy order migration after", lastID.Hex())
+		}
+	}
+
+	legacyCollection := model.Db.Collection(SourceLegacy)
+	v2Collection := model.Db.Collection(SourceV2)
+	for {
+		filter := bson.M{}
+		if !result.LastID.IsZero() {
+			filter["_id"] = bson.M{"$gt": result.LastID}
+		}
+		cursor, err := legacyCollection.Find(ctx, filter, options.Find().
+			SetSort(bson.D{{Key: "_id", Value: 1}}).
+			SetLimit(int64(opts.BatchSize)))
+		if err != nil {
+			return result, err
+		}
+		var batch []Legacy
+		if err := cursor.All(ctx, &batch); err != nil {
+			return result, err
+		}
+		if len(batch) == 0 {
+			return result, nil
+		}
+
+		for _, l := range batch {
+			result.Scanned++
+			result.LastID = l.ID
+			v, err := LegacyToV2(l)
+			if err == ErrMissingTradeNo {
+				log.Printf("Skipping legacy order %s: %v", l.ID.Hex(), err)
+				result.Skipped = append(result.Skipped, l.ID.Hex())
+				continue
+			}
+
+			// Data_id 已被其他訂單使用時不轉移，需人工處理
+			conflict, err := v2Collection.CountDocuments(ctx, bson.M{
+				"OrderStatus.Data_id": v.OrderStatus.DataID,
+				"LegacyID":            bson.M{"$ne": l.ID},
+			})
+			if err != nil {
+				return result, err
+			}
+			if conflict > 0 {
+				log.Printf("Skipping legacy order %s: Data_id %s already exists", l.ID.Hex(), v.OrderStatus.DataID)
+				result.Conflicts = append(result.Conflicts, l.ID.Hex())
+				continue
+			}
+
+			if opts.DryRun {
+				exists, err := v2Collection.CountDocuments(ctx, bson.M{"LegacyID": l.ID})
+				if err != nil {
+					return result, err
+				}
+				if exists > 0 {
+					result.Existing++
+				} else {
+					result.Inserted++
+				}
+				continue
+			}
+
+			inserted, err := migrateOne(ctx, l.ID, v)
+			if err != nil {
+				return result, err
+			}
+			if inserted {
+				result.Inserted++
+			} else {
+				result.Existing++
+			}
+		}
+
+		if !opts.DryRun {
+			if err := saveCheckpoint(ctx, result.LastID); err != nil {
+				return result, err
+			}
+		}
+		log.Printf("Migrated batch up to %s: scanned=%d inserted=%d existing=%d conflicts=%d skipped=%d",
+			result.LastID.Hex(), result.Scanned, result.Inserted, result.Existing, len(result.Conflicts), len(result.Skipped))
+	}
+}
+
+// migrateOne 在同一個交易中寫入 orderv2 並標記舊訂單，讀取時不會同時看到兩筆或都看不到，
+// orderv2 以 LegacyID 的唯一索引避免重複寫入
+func migrateOne(ctx context.Context, legacyID primitive.ObjectID, v V2) (bool, error) {
+	session, err := model.Db.Client().StartSession()
+	if err != nil {
+		return false, err
+	}
+	defer session.EndSession(ctx)
+
+	inserted := false
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		res, err := model.Db.Collection(SourceV2).UpdateOne(sc,
+			bson.M{"LegacyID": legacyID},
+			bson.M{"$setOnInsert": v},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return nil, err
+		}
+		inserted = res.UpsertedCount > 0
+		_, err = model.Db.Collection(SourceLegacy).UpdateByID(sc, legacyID, bson.M{"$set": bson.M{"Migrated": true}})
+		return nil, err
+	})
+	return inserted, err
+}
+
+// VerifyLegacy 比對舊訂單與轉移後訂單的已付款數量與金額，並確認 CountPaid 的結果沒有重複或遺漏
+func VerifyLegacy(ctx context.Context) (Verification, error) {
+	var v Verification
+
+	var legacy []Legacy
+	if err := findAll(ctx, SourceLegacy, bson.M{}, &legacy); err != nil {
+		return v, err
+	}
+	for _, l := range legacy {
+		if !l.Migrated {
+			v.Unmigrated++
+		}
+		if l.OrderStatus.TradeStatus == "1" {
+			v.LegacyPaid++
+			v.LegacyTotal += legacyAmount(l)
+		}
+	}
+
+	var migrated []V2
+	if err := findAll(ctx, SourceV2, bson.M{"Source": SourceLegacy}, &migrated); err != nil {
+		return v, err
+	}
+	for _, m := range migrated {
+		if m.OrderStatus.Amt > 0 && m.OrderStatus.Amt == m.OrderStatus.Amount {
+			v.MigratedPaid++
+			v.MigratedTotal += m.OrderStatus.Amount
+		}
+	}
+
+	// 轉移前 getOrders 的結果為舊訂單已付款數加上新訂單已付款數
+	filter := catalog.OrderFilter(catalog.DefaultProductID)
+	filter["OrderStatus.Amt"] = bson.M{"$exists": true}
+	filter["Source"] = bson.M{"$ne": SourceLegacy}
+	v2Paid, err := model.Db.Collection(SourceV2).CountDocuments(ctx, filter)
+	if err != nil {
+		return v, err
+	}
+	v.ExpectedOrders = v.LegacyPaid + int(v2Paid)
+
+	v.ReportedOrders, err = CountPaid(ctx, catalog.DefaultProductID)
+	return v, err
+}
+
+// loadCheckpoint 讀取上次轉移到的 _id
+func loadCheckpoint(ctx context.Context) (primitive.ObjectID, error) {
+	var checkpoint struct {
+		LastID primitive.ObjectID `bson:"LastID"`
+	}
+	err := model.Db.Collection("migration_checkpoints").FindOne(ctx, bson.M{"_id": legacyCheckpointID}).Decode(&checkpoint)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, nil
+	}
+	return checkpoint.LastID, err
+}
+
+// saveCheckpoint 記錄目前轉移到的 _id
+func saveCheckpoint(ctx context.Context, lastID primitive.ObjectID) error {
+	_, err := model.Db.Collection("migration_checkpoints").UpdateOne(ctx,
+		bson.M{"_id": legacyCheckpointID},
+		bson.M{"$set": bson.M{"LastID": lastID, "UpdatedAt": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
@@ -0,0 +1,244 @@
+package orders
+
+import (
+	"time"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/risk"
+	"yt-api/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 訂單來源 collection
+const (
+	SourceLegacy = "orders"
+	SourceV2     = "orderv2"
+)
+
+// Status 為統一後的訂單狀態
+type Status string
+
+const (
+	StatusUnpaid  Status = "Unpaid"
+	StatusPaid    Status = "Paid"
+	StatusExpired Status = "Expired"
+	StatusHeld    Status = "Held"
+	// StatusCancelled 為用戶在付款前取消的訂單
+	StatusCancelled Status = "Cancelled"
+	// StatusRejected 為風險審核未通過、不會出貨的訂單
+	StatusRejected Status = "Rejected"
+)
+
+// 訂單使用的時間格式
+const (
+	dataIDLayout   = "20060102150405"
+	dateTimeLayout = "2006/01/02 15:04:05"
+)
+
+// Legacy 為舊 orders collection 的訂單，TradeStatus 為 "1" 表示已付款
+type Legacy struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	SteamID     string             `bson:"SteamID"`
+	Price       int                `bson:"Price"`
+	Count       int                `bson:"Count"`
+	OrderStatus struct {
+		Amt         int    `bson:"Amt"`
+		TradeNo     string `bson:"TradeNo"`
+		PayInfo     string `bson:"PayInfo"`
+		PaymentType string `bson:"PaymentType"`
+		TradeStatus string `bson:"TradeStatus"`
+		ExpireDate  string `bson:"ExpireDate"`
+		PayTime     string `bson:"PayTime"`
+	} `bson:"OrderStatus"`
+	// Migrated 為 true 表示已轉移至 orderv2，讀取時應略過
+	Migrated bool `bson:"Migrated,omitempty"`
+}
+
+// V2 為 orderv2 collection 的 SmilePay 訂單，Amt 等於 Amount 表示已付款
+type V2 struct {
+	SteamID     string `bson:"SteamID" json:"SteamID"`
+	ProductID   string `bson:"ProductID,omitempty" json:"ProductID,omitempty"`
+	Price       int    `bson:"Price" json:"Price"`
+	Count       int    `bson:"Count" json:"Count"`
+	OrderStatus struct {
+		SmilePayNO string `bson:"SmilePayNO" json:"SmilePayNO"`
+		DataID     string `bson:"Data_id" json:"Data_id"`
+		Amount     int    `bson:"Amount" json:"Amount"`
+		PayEndDate string `bson:"PayEndDate" json:"PayEndDate"`
+		PayMethod  string `bson:"PayMethod" json:"PayMethod"`
+		AtmBankNo  string `bson:"AtmBankNo" json:"AtmBankNo"`
+		AtmNo      string `bson:"AtmNo" json:"AtmNo"`
+		IbonNo     string `bson:"IbonNo" json:"IbonNo"`
+		FamiNO     string `bson:"FamiNO" json:"FamiNO"`
+		// PayInfo 為舊訂單轉移時保留的繳費資訊
+		PayInfo string `bson:"PayInfo,omitempty" json:"PayInfo,omitempty"`
+		// Callback Data，付款前不寫入，getOrders 以 Amt 是否存在判斷已付款
+		ProcessDate string `bson:"Process_date,omitempty" json:"Process_date"`
+		ProcessTime string `bson:"Process_time,omitempty" json:"Process_time"`
+		Amt         int    `bson:"Amt,omitempty" json:"Amt"`
+	} `bson:"OrderStatus" json:"OrderStatus"`
+	Fee       int       `bson:"Fee,omitempty" json:"Fee,omitempty"`
+	QuoteID   string    `bson:"QuoteID,omitempty" json:"QuoteID,omitempty"`
+	CreatedAt time.Time `bson:"CreatedAt,omitempty" json:"CreatedAt,omitempty"`
+	// DeliveryHold 為 true 時需管理員核准才能出貨，機器人應略過這類訂單
+	DeliveryHold bool             `bson:"DeliveryHold,omitempty" json:"-"`
+	Risk         *risk.Assessment `bson:"Risk,omitempty" json:"-"`
+	// Source 為 SourceLegacy 時表示由舊 orders collection 轉移，Data_id 沿用 TradeNo
+	Source   string             `bson:"Source,omitempty" json:"Source,omitempty"`
+	LegacyID primitive.ObjectID `bson:"LegacyID,omitempty" json:"-"`
+	// CancelledAt 為用戶取消未付款訂單的時間，取消後才付款仍視為已付款
+	CancelledAt *time.Time `bson:"CancelledAt,omitempty" json:"CancelledAt,omitempty"`
+}
+
+// Payment 為尚未付款訂單的繳費資訊
+type Payment struct {
+	PayEndDate string `json:"PayEndDate"`
+	AtmBankNo  string `json:"AtmBankNo,omitempty"`
+	AtmNo      string `json:"AtmNo,omitempty"`
+	IbonNo     string `json:"IbonNo,omitempty"`
+	FamiNO     string `json:"FamiNO,omitempty"`
+	// PayInfo 為舊訂單的繳費資訊
+	PayInfo string `json:"PayInfo,omitempty"`
+}
+
+// Order 為兩個 collection 統一後的訂單
+type Order struct {
+	Source    string   `json:"Source"`
+	SteamID   string   `json:"SteamID"`
+	ProductID string   `json:"ProductID"`
+	Price     int      `json:"Price"`
+	Count     int      `json:"Count"`
+	Amount    int      `json:"Amount"`
+	Fee       int      `json:"Fee,omitempty"`
+	OrderId   string   `json:"OrderId"`
+	OrderDate string   `json:"OrderDate"`
+	PayDate   string   `json:"PayDate,omitempty"`
+	PayMethod string   `json:"PayMethod"`
+	Status    Status   `json:"Status"`
+	Payment   *Payment `json:"Payment,omitempty"`
+	// CreatedAt 用於排序，由訂單編號或 ObjectID 推算
+	CreatedAt time.Time `json:"-"`
+	// PayEndDate 為繳費期限，只用於轉換為 v1 格式，v2 只在 Payment 中提供
+	PayEndDate string `json:"-"`
+}
+
+// Paid 回傳訂單是否已付款且會出貨，包含等待審核的訂單，不含審核未通過的訂單
+func (o Order) Paid() bool {
+	return o.Status == StatusPaid || o.Status == StatusHeld
+}
+
+// FromV2 將 orderv2 訂單轉換為統一格式
+func FromV2(v V2) Order {
+	o := Order{
+		Source:     SourceV2,
+		SteamID:    v.SteamID,
+		ProductID:  v.ProductID,
+		Price:      v.Price,
+		Count:      v.Count,
+		Amount:     v.OrderStatus.Amount,
+		Fee:        v.Fee,
+		OrderId:    v.OrderStatus.DataID,
+		OrderDate:  v.OrderStatus.DataID,
+		PayMethod:  v.OrderStatus.PayMethod,
+		PayEndDate: v.OrderStatus.PayEndDate,
+	}
+	if o.ProductID == "" {
+		o.ProductID = catalog.DefaultProductID
+	}
+	if v.Source != "" {
+		o.Source = v.Source
+	}
+	if t, err := time.ParseInLocation(dataIDLayout, v.OrderStatus.DataID, utils.Taipei); err == nil {
+		o.CreatedAt = t
+		o.OrderDate = t.Format(dateTimeLayout)
+	} else if !v.CreatedAt.IsZero() {
+		o.CreatedAt = v.CreatedAt.In(utils.Taipei)
+		o.OrderDate = o.CreatedAt.Format(dateTimeLayout)
+	}
+
+	paid := v.OrderStatus.Amt == v.OrderStatus.Amount
+	if paid {
+		o.PayDate = v.OrderStatus.ProcessDate + " " + v.OrderStatus.ProcessTime
+	}
+
+	switch {
+	case v.Risk != nil && v.Risk.Status == risk.StatusRejected:
+		o.Status = StatusRejected
+	case paid:
+		o.Status = StatusPaid
+		if v.DeliveryHold {
+			o.Status = StatusHeld
+		}
+	case v.CancelledAt != nil:
+		o.Status = StatusCancelled
+	case expired(v.OrderStatus.PayEndDate):
+		o.Status = StatusExpired
+	default:
+		o.Status = StatusUnpaid
+		o.Payment = &Payment{
+			PayEndDate: v.OrderStatus.PayEndDate,
+			AtmBankNo:  v.OrderStatus.AtmBankNo,
+			AtmNo:      v.OrderStatus.AtmNo,
+			IbonNo:     v.OrderStatus.IbonNo,
+			FamiNO:     v.OrderStatus.FamiNO,
+			PayInfo:    v.OrderStatus.PayInfo,
+		}
+	}
+	return o
+}
+
+// legacyAmount 回傳舊訂單的金額，沒有 Amt 時以單價乘以數量計算
+func legacyAmount(l Legacy) int {
+	if l.OrderStatus.Amt > 0 {
+		return l.OrderStatus.Amt
+	}
+	return l.Price * l.Count
+}
+
+// FromLegacy 將舊 orders collection 的訂單轉換為統一格式，舊訂單只有預設商品
+func FromLegacy(l Legacy) Order {
+	amount := legacyAmount(l)
+	o := Order{
+		Source:     SourceLegacy,
+		SteamID:    l.SteamID,
+		ProductID:  catalog.DefaultProductID,
+		Price:      l.Price,
+		Count:      l.Count,
+		Amount:     amount,
+		OrderId:    l.OrderStatus.TradeNo,
+		PayMethod:  l.OrderStatus.PaymentType,
+		PayEndDate: l.OrderStatus.ExpireDate,
+	}
+	if !l.ID.IsZero() {
+		o.CreatedAt = l.ID.Timestamp().In(utils.Taipei)
+		o.OrderDate = o.CreatedAt.Format(dateTimeLayout)
+	}
+
+	switch {
+	case l.OrderStatus.TradeStatus == "1":
+		o.PayDate = l.OrderStatus.PayTime
+		o.Status = StatusPaid
+	case expired(l.OrderStatus.ExpireDate):
+		o.Status = StatusExpired
+	default:
+		o.Status = StatusUnpaid
+		o.Payment = &Payment{
+			PayEndDate: l.OrderStatus.ExpireDate,
+			PayInfo:    l.OrderStatus.PayInfo,
+		}
+	}
+	return o
+}
+
+// expired 判斷繳費期限是否已過，無法解析時視為已過期
+func expired(deadline string) bool {
+	if t, err := time.ParseInLocation(dateTimeLayout, deadline, utils.Taipei); err == nil {
+		return t.Before(time.Now())
+	}
+	// 舊訂單的期限只有日期，當天結束前都可繳費
+	if t, err := time.ParseInLocation("2006/01/02", deadline, utils.Taipei); err == nil {
+		return t.Add(24 * time.Hour).Before(time.Now())
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+package orders
+
+import (
+	"testing"
+	"time"
+
+	"yt-api/internal/risk"
+	"yt-api/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func deadline(d time.Duration) string {
+	return time.Now().Add(d).In(utils.Taipei).Format(dateTimeLayout)
+}
+
+func v2Order(amt int, payEnd string, hold bool, cancelled bool) V2 {
+	var v V2
+	v.SteamID = "76561198000000000"
+	v.Price = 100
+	v.Count = 2
+	v.OrderStatus.DataID = "20240102030405"
+	v.OrderStatus.Amount = 200
+	v.OrderStatus.Amt = amt
+	v.OrderStatus.PayEndDate = payEnd
+	v.DeliveryHold = hold
+	if cancelled {
+		now := time.Now()
+		v.CancelledAt = &now
+	}
+	return v
+}
+
+func rejected(v V2) V2 {
+	v.Risk = &risk.Assessment{Hold: true, Status: risk.StatusRejected}
+	return v
+}
+
+func TestFromV2(t *testing.T) {
+	tests := []struct {
+		name        string
+		v           V2
+		want        Status
+		wantPayment bool
+	}{
+		{"paid", v2Order(200, deadline(-time.Hour), false, false), StatusPaid, false},
+		{"paid with delivery hold", v2Order(200, deadline(time.Hour), true, false), StatusHeld, false},
+		{"paid after cancel stays paid", v2Order(200, deadline(time.Hour), false, true), StatusPaid, false},
+		{"cancelled", v2Order(0, deadline(time.Hour), false, true), StatusCancelled, false},
+		{"expired", v2Order(0, deadline(-time.Hour), false, false), StatusExpired, false},
+		{"unparsable deadline is expired", v2Order(0, "", false, false), StatusExpired, false},
+		{"unpaid", v2Order(0, deadline(time.Hour), false, false), StatusUnpaid, true},
+		{"partial payment is unpaid", v2Order(100, deadline(time.Hour), false, false), StatusUnpaid, true},
+		{"rejected after payment", rejected(v2Order(200, deadline(time.Hour), true, false)), StatusRejected, false},
+		{"rejected before payment", rejected(v2Order(0, deadline(time.Hour), true, false)), StatusRejected, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := FromV2(tt.v)
+			if o.Status != tt.want {
+				t.Errorf("Status = %q, want %q", o.Status, tt.want)
+			}
+			if (o.Payment != nil) != tt.wantPayment {
+				t.Errorf("Payment = %+v, want present %v", o.Payment, tt.wantPayment)
+			}
+			if o.Paid() != (tt.want == StatusPaid || tt.want == StatusHeld) {
+				t.Errorf("Paid() = %v for status %q", o.Paid(), o.Status)
+			}
+		})
+	}
+}
+
+func legacyOrder(tradeStatus, expireDate string) Legacy {
+	var l Legacy
+	l.ID = primitive.NewObjectID()
+	l.SteamID = "76561198000000000"
+	l.Price = 100
+	l.Count = 3
+	l.OrderStatus.TradeNo = "T123"
+	l.OrderStatus.TradeStatus = tradeStatus
+	l.OrderStatus.ExpireDate = expireDate
+	l.OrderStatus.PayTime = "2024/01/02 03:04:05"
+	return l
+}
+
+func TestFromLegacy(t *testing.T) {
+	today := time.Now().In(utils.Taipei).Format("2006/01/02")
+	past := time.Now().In(utils.Taipei).AddDate(0, 0, -2).Format("2006/01/02")
+
+	tests := []struct {
+		name        string
+		l           Legacy
+		want        Status
+		wantPayment bool
+	}{
+		{"paid", legacyOrder("1", past), StatusPaid, false},
+		{"expired date only", legacyOrder("0", past), StatusExpired, false},
+		{"date only payable until end of day", legacyOrder("0", today), StatusUnpaid, true},
+		{"expired date time", legacyOrder("0", deadline(-time.Hour)), StatusExpired, false},
+		{"unpaid", legacyOrder("0", deadline(time.Hour)), StatusUnpaid, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := FromLegacy(tt.l)
+			if o.Status != tt.want {
+				t.Errorf("Status = %q, want %q", o.Status, tt.want)
+			}
+			if (o.Payment != nil) != tt.wantPayment {
+				t.Errorf("Payment = %+v, want present %v", o.Payment, tt.wantPayment)
+			}
+			if o.Amount != 300 {
+				t.Errorf("Amount = %d, want 300", o.Amount)
+			}
+		})
+	}
+}
+
+func TestLegacyToV2(t *testing.T) {
+	if _, err := LegacyToV2(legacyOrder("1", "")); err != nil {
+		t.Fatalf("LegacyToV2() error = %v", err)
+	}
+
+	missing := legacyOrder("1", "")
+	missing.OrderStatus.TradeNo = ""
+	if _, err := LegacyToV2(missing); err != ErrMissingTradeNo {
+		t.Errorf("LegacyToV2() without TradeNo error = %v, want %v", err, ErrMissingTradeNo)
+	}
+
+	// 轉移後的狀態應與舊訂單直接轉換的狀態相同
+	tests := []struct {
+		name string
+		l    Legacy
+	}{
+		{"paid", legacyOrder("1", "2020/01/01")},
+		{"expired date only", legacyOrder("0", "2020/01/01")},
+		{"unpaid", legacyOrder("0", deadline(time.Hour))},
+		{"unpaid date only", legacyOrder("0", time.Now().In(utils.Taipei).Format("2006/01/02"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := LegacyToV2(tt.l)
+			if err != nil {
+				t.Fatalf("LegacyToV2() error = %v", err)
+			}
+			if got, want := FromV2(v).Status, FromLegacy(tt.l).Status; got != want {
+				t.Errorf("migrated Status = %q, legacy Status = %q", got, want)
+			}
+			if v.OrderStatus.DataID != tt.l.OrderStatus.TradeNo {
+				t.Errorf("Data_id = %q, want %q", v.OrderStatus.DataID, tt.l.OrderStatus.TradeNo)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/model"
+	"yt-api/internal/risk"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrOrderNotFound  = errors.New("order not found")
+	ErrNotCancellable = errors.New("only unpaid orders can be cancelled")
+)
+
+// Stats 為一位用戶的訂單統計
+type Stats struct {
+	CompletedOrders int
+	ActiveOrders    int
+	// PaidKeys 不包含等待風險審核的訂單
+	PaidKeys   int
+	HeldKeys   int
+	TotalSpent int
+}
+
+// Find 讀取 orders 與 orderv2 中的訂單並依建立時間由新到舊排序，steamID 為空時回傳所有訂單
+func Find(ctx context.Context, steamID string) ([]Order, error) {
+	filter := bson.M{}
+	if steamID != "" {
+		filter["SteamID"] = steamID
+	}
+
+	// 已轉移的舊訂單會出現在 orderv2 中
+	legacyFilter := bson.M{"Migrated": bson.M{"$ne": true}}
+	for k, v := range filter {
+		legacyFilter[k] = v
+	}
+	var legacy []Legacy
+	if err := findAll(ctx, SourceLegacy, legacyFilter, &legacy); err != nil {
+		return nil, err
+	}
+	var v2 []V2
+	if err := findAll(ctx, SourceV2, filter, &v2); err != nil {
+		return nil, err
+	}
+
+	result := make([]Order, 0, len(legacy)+len(v2))
+	for _, l := range legacy {
+		result = append(result, FromLegacy(l))
+	}
+	for _, v := range v2 {
+		result = append(result, FromV2(v))
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+// Get 以訂單編號查詢訂單，orderv2 的 Data_id 優先於舊訂單的 TradeNo
+func Get(ctx context.Context, id string) (*Order, error) {
+	var v V2
+	err := model.Db.Collection(SourceV2).FindOne(ctx, bson.M{"OrderStatus.Data_id": id}).Decode(&v)
+	if err == nil {
+		o := FromV2(v)
+		return &o, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	var l Legacy
+	err = model.Db.Collection(SourceLegacy).FindOne(ctx, bson.M{"OrderStatus.TradeNo": id}).Decode(&l)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	o := FromLegacy(l)
+	return &o, nil
+}
+
+// GetStats 統計用戶在兩個 collection 中的訂單
+func GetStats(ctx context.Context, steamID string) (Stats, error) {
+	list, err := Find(ctx, steamID)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	var stats Stats
+	for _, o := range list {
+		switch o.Status {
+		case StatusPaid:
+			stats.CompletedOrders++
+			stats.PaidKeys += o.Count
+			stats.TotalSpent += o.Amount
+		case StatusHeld:
+			// 風險審核中的訂單暫不計入可領取數量
+			stats.CompletedOrders++
+			stats.HeldKeys += o.Count
+			stats.TotalSpent += o.Amount
+		case StatusUnpaid:
+			stats.ActiveOrders++
+		}
+	}
+	return stats, nil
+}
+
+// CountPaid 統計商品的已付款訂單數，不含審核未通過的訂單，舊 orders collection 只有預設商品
+func CountPaid(ctx context.Context, productID string) (int, error) {
+	var legacy int64
+	if productID == "" || productID == catalog.DefaultProductID {
+		var err error
+		legacy, err = model.Db.Collection(SourceLegacy).CountDocuments(ctx, bson.M{
+			"OrderStatus.TradeStatus": "1",
+			"Migrated":                bson.M{"$ne": true},
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	filter := catalog.OrderFilter(productID)
+	filter["OrderStatus.Amt"] = bson.M{"$exists": true}
+	filter["Risk.Status"] = bson.M{"$ne": risk.StatusRejected}
+	v2, err := model.Db.Collection(SourceV2).CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return int(legacy + v2), nil
+}
+
+// findAll 查詢 collection 並解碼所有結果
+func findAll(ctx context.Context, collection string, filter bson.M, results interface{}) error {
+	cursor, err := model.Db.Collection(collection).Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, results)
+}
+
+// Cancel 取消用戶自己尚未付款且未過期的 orderv2 訂單，其他用戶的訂單回傳 ErrOrderNotFound
+func Cancel(ctx context.Context, id, steamID string) (*Order, error) {
+	order, err := Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order.SteamID != steamID {
+		return nil, ErrOrderNotFound
+	}
+	if order.Source != SourceV2 || order.Status != StatusUnpaid {
+		return nil, ErrNotCancellable
+	}
+
+	var v V2
+	err = model.Db.Collection(SourceV2).FindOneAndUpdate(ctx,
+		bson.M{
+			"OrderStatus.Data_id": id,
+			"SteamID":             steamID,
+			"OrderStatus.Amt":     bson.M{"$exists": false},
+			"CancelledAt":         bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{"CancelledAt": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&v)
+	// 同時收到付款通知
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotCancellable
+	}
+	if err != nil {
+		return nil, err
+	}
+	o := FromV2(v)
+	return &o, nil
+}
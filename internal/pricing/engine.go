@@ -0,0 +1,235 @@
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/model"
+	"yt-api/internal/steam"
+	"yt-api/internal/stock"
+	"yt-api/internal/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StockSurcharge 表示庫存低於門檻時的加價規則
+type StockSurcharge struct {
+	StockBelow int     `json:"stockBelow"`
+	Percent    float64 `json:"percent"`
+}
+
+// Rules 表示定價引擎的設定，透過 PRICING_RULES (JSON) 覆寫預設值
+type Rules struct {
+	MarginPercent   float64          `json:"marginPercent"`
+	Floor           int              `json:"floor"`
+	Ceiling         int              `json:"ceiling"`
+	StockSurcharges []StockSurcharge `json:"stockSurcharges"`
+	RoundTo         int              `json:"roundTo"`
+}
+
+// Step 記錄計算過程中的一個步驟，供 dry-run 說明價格來源
+type Step struct {
+	Rule   string  `json:"rule"`
+	Price  float64 `json:"price"`
+	Detail string  `json:"detail"`
+}
+
+// Result 表示一次定價計算的結果
+type Result struct {
+	ProductID    string `json:"productId"`
+	MarketPrice  int    `json:"marketPrice"`
+	Stock        int    `json:"stock"`
+	CurrentPrice int    `json:"currentPrice"`
+	Price        int    `json:"price"`
+	Changed      bool   `json:"changed"`
+	Applied      bool   `json:"applied"`
+	// Paused 為 true 表示暫停販售中，計算結果不會寫入
+	Paused bool   `json:"paused"`
+	Steps  []Step `json:"steps"`
+	Rules  Rules  `json:"rules"`
+}
+
+// OnPriceChanged 在引擎寫入新價格後被呼叫，用來讓狀態快取失效
+var OnPriceChanged func()
+
+// defaultRules 回傳預設的定價規則，每次建立新的 StockSurcharges，
+// 解析 PRICING_RULES 時 json.Unmarshal 會沿用原本的 slice 寫入
+func defaultRules() Rules {
+	return Rules{
+		MarginPercent: 10,
+		Floor:         0,
+		Ceiling:       0,
+		StockSurcharges: []StockSurcharge{
+			{StockBelow: 50, Percent: 3},
+			{StockBelow: 10, Percent: 8},
+		},
+		RoundTo: 5,
+	}
+}
+
+// LoadRules 讀取定價規則，PRICING_RULES 未設定或格式錯誤時使用預設值
+func LoadRules() Rules {
+	rules := defaultRules()
+	raw := os.Getenv("PRICING_RULES")
+	if raw == "" {
+		return rules
+	}
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		log.Println("Error parsing PRICING_RULES, using defaults:", err)
+		return defaultRules()
+	}
+	return rules
+}
+
+// Compute 依市場價格 (TWD，以分為單位) 與庫存計算售價
+func Compute(rules Rules, marketPrice, stock int) Result {
+	result := Result{MarketPrice: marketPrice, Stock: stock, Rules: rules}
+
+	price := float64(marketPrice) / 100
+	result.Steps = append(result.Steps, Step{Rule: "market", Price: price, Detail: "Steam 市場最低售價"})
+
+	price *= 1 + rules.MarginPercent/100
+	result.Steps = append(result.Steps, Step{Rule: "margin", Price: price, Detail: fmt.Sprintf("利潤 %.2f%%", rules.MarginPercent)})
+
+	surcharge := 0.0
+	for _, s := range rules.StockSurcharges {
+		if stock < s.StockBelow && s.Percent > surcharge {
+			surcharge = s.Percent
+		}
+	}
+	if surcharge > 0 {
+		price *= 1 + surcharge/100
+		result.Steps = append(result.Steps, Step{Rule: "stock", Price: price, Detail: fmt.Sprintf("庫存 %d 加價 %.2f%%", stock, surcharge)})
+	}
+
+	// 先四捨五入到分，避免浮點誤差 (例如 50 * 1.1 = 55.000000000000007) 被無條件進位
+	price = math.Round(price*100) / 100
+	if rules.RoundTo > 1 {
+		price = math.Ceil(price/float64(rules.RoundTo)) * float64(rules.RoundTo)
+		result.Steps = append(result.Steps, Step{Rule: "round", Price: price, Detail: fmt.Sprintf("無條件進位至 %d 的倍數", rules.RoundTo)})
+	} else {
+		price = math.Ceil(price)
+	}
+
+	if rules.Floor > 0 && price < float64(rules.Floor) {
+		price = float64(rules.Floor)
+		result.Steps = append(result.Steps, Step{Rule: "floor", Price: price, Detail: fmt.Sprintf("最低售價 %d", rules.Floor)})
+	}
+	if rules.Ceiling > 0 && price > float64(rules.Ceiling) {
+		price = float64(rules.Ceiling)
+		result.Steps = append(result.Steps, Step{Rule: "ceiling", Price: price, Detail: fmt.Sprintf("最高售價 %d", rules.Ceiling)})
+	}
+
+	result.Price = int(price)
+	return result
+}
+
+// Run 取得商品最新市場價格與庫存並計算售價，dryRun 為 false 且未暫停販售時寫入商品的價格 key，
+// 庫存以扣除預留後的可售數量計算，價格 key 不存在時視為 0
+func Run(ctx context.Context, product catalog.Product, dryRun bool) (*Result, error) {
+	rules := LoadRules()
+
+	currency, _ := steam.LookupMarketCurrency("TWD")
+	marketPrice, err := steam.GetMarketPrice(ctx, steam.MarketSource{ItemNameID: product.ItemNameID, Currency: currency})
+	if err != nil {
+		return nil, err
+	}
+	if marketPrice <= 0 {
+		return nil, errors.New("market price unavailable")
+	}
+
+	// 以扣除預留數量後的可售庫存計算加價，與下單時的庫存檢查一致
+	level, err := stock.Get(ctx, product)
+	if err != nil {
+		return nil, err
+	}
+	available := level.Available
+	currentPrice, err := readInt(ctx, product.PriceKey)
+	if err != nil {
+		return nil, err
+	}
+
+	result := Compute(rules, marketPrice, available)
+	result.ProductID = product.ID
+	result.CurrentPrice = currentPrice
+	result.Changed = result.Price != currentPrice
+	if dryRun || !result.Changed {
+		return &result, nil
+	}
+
+	// 暫停販售期間不調整價格，避免恢復販售時價格已經變動
+	paused, err := model.RedisClient.Get(ctx, "REDIS_SALES_PAUSED").Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	if paused == "1" {
+		result.Paused = true
+		return &result, nil
+	}
+
+	if err := model.RedisClient.Set(ctx, product.PriceKey, result.Price, 0).Err(); err != nil {
+		return nil, err
+	}
+	result.Applied = true
+	log.Printf("Pricing engine updated %s price from %d to %d", product.ID, currentPrice, result.Price)
+
+	model.WriteAudit(ctx, model.AuditEntry{
+		Action:   "price.set",
+		Actor:    "pricing-engine",
+		Target:   product.ID,
+		Reason:   fmt.Sprintf("market price %d, stock %d", marketPrice, available),
+		Previous: currentPrice,
+		Value:    result.Price,
+	})
+	if OnPriceChanged != nil {
+		OnPriceChanged()
+	}
+	return &result, nil
+}
+
+// StartScheduler 在 PRICING_ENABLED=true 時依 PRICING_INTERVAL 定期更新所有商品的售價
+func StartScheduler() {
+	if os.Getenv("PRICING_ENABLED") != "true" {
+		return
+	}
+	interval := utils.GetEnvDuration("PRICING_INTERVAL", 10*time.Minute)
+	log.Println("Pricing engine enabled, interval:", interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for ; true; <-ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			products, err := catalog.List(ctx)
+			if err != nil {
+				log.Println("Error listing products for pricing engine:", err)
+			}
+			for _, product := range products {
+				if _, err := Run(ctx, product, false); err != nil {
+					log.Printf("Error running pricing engine for %s: %v", product.ID, err)
+				}
+			}
+			cancel()
+		}
+	}()
+}
+
+// readInt 讀取整數 key，key 不存在時回傳 0
+func readInt(ctx context.Context, key string) (int, error) {
+	value, err := model.RedisClient.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,80 @@
+package pricing
+
+import "testing"
+
+func TestCompute(t *testing.T) {
+	rules := Rules{
+		MarginPercent: 10,
+		StockSurcharges: []StockSurcharge{
+			{StockBelow: 50, Percent: 3},
+			{StockBelow: 10, Percent: 8},
+		},
+		RoundTo: 5,
+	}
+	tests := []struct {
+		name        string
+		rules       Rules
+		marketPrice int
+		stock       int
+		want        int
+		wantRules   []string
+	}{
+		{"margin and rounding", rules, 5000, 100, 55, []string{"market", "margin", "round"}},
+		{"rounds up to multiple", rules, 5100, 100, 60, []string{"market", "margin", "round"}},
+		{"low stock surcharge", rules, 5000, 20, 60, []string{"market", "margin", "stock", "round"}},
+		{"largest matching surcharge applies", rules, 5000, 5, 60, []string{"market", "margin", "stock", "round"}},
+		{"floor", Rules{MarginPercent: 10, RoundTo: 5, Floor: 70}, 5000, 100, 70, []string{"market", "margin", "round", "floor"}},
+		{"ceiling", Rules{MarginPercent: 10, RoundTo: 5, Ceiling: 50}, 5000, 100, 50, []string{"market", "margin", "round", "ceiling"}},
+		{"no rounding rounds up to integer", Rules{MarginPercent: 10}, 5001, 100, 56, []string{"market", "margin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Compute(tt.rules, tt.marketPrice, tt.stock)
+			if result.Price != tt.want {
+				t.Errorf("Compute() price = %d, want %d", result.Price, tt.want)
+			}
+			if len(result.Steps) != len(tt.wantRules) {
+				t.Fatalf("Compute() steps = %+v, want rules %v", result.Steps, tt.wantRules)
+			}
+			for i, step := range result.Steps {
+				if step.Rule != tt.wantRules[i] {
+					t.Errorf("step %d rule = %s, want %s", i, step.Rule, tt.wantRules[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    string
+		margin float64
+	}{
+		{"unset uses defaults", "", defaultRules().MarginPercent},
+		{"override", `{"marginPercent": 25}`, 25},
+		{"invalid json uses defaults", `{"marginPercent":`, defaultRules().MarginPercent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PRICING_RULES", tt.env)
+			if got := LoadRules().MarginPercent; got != tt.margin {
+				t.Errorf("LoadRules().MarginPercent = %v, want %v", got, tt.margin)
+			}
+		})
+	}
+}
+
+func TestLoadRulesKeepsDefaults(t *testing.T) {
+	t.Setenv("PRICING_RULES", `{"stockSurcharges": [{"stockBelow": 5, "percent": 50}]}`)
+	if got := LoadRules().StockSurcharges; len(got) != 1 || got[0].Percent != 50 {
+		t.Fatalf("LoadRules().StockSurcharges = %v, want override", got)
+	}
+
+	// 覆寫的規則不能寫入預設值
+	t.Setenv("PRICING_RULES", "")
+	got := LoadRules().StockSurcharges
+	if len(got) != 2 || got[0] != (StockSurcharge{StockBelow: 50, Percent: 3}) || got[1] != (StockSurcharge{StockBelow: 10, Percent: 8}) {
+		t.Errorf("LoadRules().StockSurcharges after override = %v, want defaults", got)
+	}
+}
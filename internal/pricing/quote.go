@@ -0,0 +1,254 @@
+package pricing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/model"
+	"yt-api/internal/utils"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Tier 表示一段數量區間的單價，Max 為 0 表示沒有上限
+type Tier struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Price int `json:"price"`
+}
+
+// Quote 表示一次報價，在有效期限內建立訂單會沿用報價金額
+type Quote struct {
+	ID        string         `json:"id"`
+	SteamID   string         `json:"steamId"`
+	ProductID string         `json:"productId"`
+	Count     int            `json:"count"`
+	UnitPrice int            `json:"unitPrice"`
+	Subtotal  int            `json:"subtotal"`
+	Fees      map[string]int `json:"fees"`
+	Method    string         `json:"method,omitempty"`
+	Fee       int            `json:"fee"`
+	Total     int            `json:"total"`
+	ExpiresAt time.Time      `json:"expiresAt"`
+}
+
+var (
+	ErrQuoteNotFound    = errors.New("quote not found or expired")
+	ErrQuoteInUse       = errors.New("quote is being used by another order")
+	ErrInvalidMethod    = errors.New("unsupported payment method")
+	ErrPriceUnavailable = errors.New("no price available")
+)
+
+// defaultPaymentFees 為各繳費方式的手續費 (TWD)，可用 PAYMENT_FEES (JSON) 覆寫
+var defaultPaymentFees = map[string]int{
+	"atm":      15,
+	"ibon":     30,
+	"famiport": 30,
+}
+
+var quoteTTL = utils.GetEnvDuration("QUOTE_TTL", 10*time.Minute)
+
+// PaymentFees 回傳各繳費方式的手續費，回傳的 map 可自由修改
+func PaymentFees() map[string]int {
+	raw := os.Getenv("PAYMENT_FEES")
+	if raw == "" {
+		return copyFees(defaultPaymentFees)
+	}
+	var fees map[string]int
+	if err := json.Unmarshal([]byte(raw), &fees); err != nil {
+		log.Println("Error parsing PAYMENT_FEES, using defaults:", err)
+		return copyFees(defaultPaymentFees)
+	}
+	return fees
+}
+
+func copyFees(fees map[string]int) map[string]int {
+	copied := make(map[string]int, len(fees))
+	for method, fee := range fees {
+		copied[method] = fee
+	}
+	return copied
+}
+
+// LoadTiers 從 Redis 讀取商品的數量級距價格表
+func LoadTiers(ctx context.Context, product catalog.Product) ([]Tier, error) {
+	raw, err := model.RedisClient.Get(ctx, product.TiersKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tiers []Tier
+	if err := json.Unmarshal([]byte(raw), &tiers); err != nil {
+		return nil, err
+	}
+	return tiers, nil
+}
+
+// UnitPrice 依購買數量取得單價，沒有符合的級距時使用商品的價格 key
+func UnitPrice(ctx context.Context, product catalog.Product, count int) (int, error) {
+	tiers, err := LoadTiers(ctx, product)
+	if err != nil {
+		log.Println("Error loading price tiers:", err)
+	}
+	if price, ok := tierPrice(tiers, count); ok {
+		return price, nil
+	}
+
+	price, err := readInt(ctx, product.PriceKey)
+	if err != nil {
+		return 0, err
+	}
+	if price <= 0 {
+		return 0, ErrPriceUnavailable
+	}
+	return price, nil
+}
+
+// tierPrice 回傳第一個包含 count 的級距單價，Min 與 Max 皆包含在級距內
+func tierPrice(tiers []Tier, count int) (int, bool) {
+	for _, tier := range tiers {
+		if count >= tier.Min && (tier.Max == 0 || count <= tier.Max) && tier.Price > 0 {
+			return tier.Price, true
+		}
+	}
+	return 0, false
+}
+
+// NewQuote 計算報價並存入 Redis，method 可為空字串，此時 Total 不含手續費
+func NewQuote(ctx context.Context, steamID string, product catalog.Product, count int, method string) (*Quote, error) {
+	fees := PaymentFees()
+	method = strings.ToLower(method)
+	if _, ok := fees[method]; method != "" && !ok {
+		return nil, ErrInvalidMethod
+	}
+
+	unitPrice, err := UnitPrice(ctx, product, count)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	quote := buildQuote(hex.EncodeToString(id), steamID, product.ID, count, unitPrice, fees, method)
+	data, err := json.Marshal(quote)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.RedisClient.Set(ctx, "QUOTE:"+quote.ID, data, quoteTTL).Err(); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// buildQuote 依單價與繳費方式計算報價金額，method 為空字串時 Total 不含手續費
+func buildQuote(id, steamID, productID string, count, unitPrice int, fees map[string]int, method string) *Quote {
+	quote := &Quote{
+		ID:        id,
+		SteamID:   steamID,
+		ProductID: productID,
+		Count:     count,
+		UnitPrice: unitPrice,
+		Subtotal:  unitPrice * count,
+		Fees:      fees,
+		Method:    method,
+		Fee:       fees[method],
+		ExpiresAt: time.Now().Add(quoteTTL),
+	}
+	quote.Total = quote.Subtotal + quote.Fee
+	return quote
+}
+
+// claimTTL 為建立訂單期間鎖定報價的時間，需長於建立訂單的逾時
+var claimTTL = 30 * time.Second
+
+// redeemScript 只在報價仍由自己鎖定時刪除報價與鎖定
+var redeemScript = redis.NewScript(`
+if redis.call('GET', KEYS[2]) ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1], KEYS[2])
+return 1
+`)
+
+// releaseClaimScript 只在鎖定仍屬於自己時解除
+var releaseClaimScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Claim 為建立訂單期間鎖定的報價，訂單寫入後呼叫 Redeem 刪除報價，失敗時呼叫 Release 讓報價可再使用
+type Claim struct {
+	Quote *Quote
+	id    string
+	token string
+}
+
+// ClaimQuote 讀取並鎖定報價，報價不存在、過期或不屬於該用戶時回傳 ErrQuoteNotFound，
+// 同一份報價正在建立訂單時回傳 ErrQuoteInUse
+func ClaimQuote(ctx context.Context, id, steamID string) (*Claim, error) {
+	data, err := model.RedisClient.Get(ctx, "QUOTE:"+id).Result()
+	if err == redis.Nil {
+		return nil, ErrQuoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var quote Quote
+	if err := json.Unmarshal([]byte(data), &quote); err != nil {
+		return nil, err
+	}
+	if quote.SteamID != steamID || time.Now().After(quote.ExpiresAt) {
+		return nil, ErrQuoteNotFound
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	claim := &Claim{Quote: &quote, id: id, token: hex.EncodeToString(token)}
+	ok, err := model.RedisClient.SetNX(ctx, "QUOTE_CLAIM:"+id, claim.token, claimTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrQuoteInUse
+	}
+	return claim, nil
+}
+
+// Redeem 在訂單寫入後刪除報價，鎖定已逾時被他人取得時回傳 ErrQuoteNotFound
+func (c *Claim) Redeem(ctx context.Context) error {
+	ok, err := redeemScript.Run(ctx, model.RedisClient, []string{"QUOTE:" + c.id, "QUOTE_CLAIM:" + c.id}, c.token).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrQuoteNotFound
+	}
+	return nil
+}
+
+// Release 解除鎖定，報價在有效期限內可再用來建立訂單
+func (c *Claim) Release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := releaseClaimScript.Run(ctx, model.RedisClient, []string{"QUOTE_CLAIM:" + c.id}, c.token).Err(); err != nil {
+		log.Printf("Error releasing quote %s: %v", c.id, err)
+	}
+}
@@ -0,0 +1,169 @@
+package pricing
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestTierPrice(t *testing.T) {
+	tiers := []Tier{
+		{Min: 1, Max: 9, Price: 100},
+		{Min: 10, Max: 49, Price: 95},
+		{Min: 50, Price: 90},
+	}
+	tests := []struct {
+		count  int
+		want   int
+		wantOK bool
+	}{
+		{0, 0, false},
+		{1, 100, true},
+		{9, 100, true},
+		{10, 95, true},
+		{49, 95, true},
+		{50, 90, true},
+		{500, 90, true},
+	}
+	for _, tt := range tests {
+		got, ok := tierPrice(tiers, tt.count)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("tierPrice(%d) = %d, %v, want %d, %v", tt.count, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	// 沒有設定價格的級距改用商品價格
+	if _, ok := tierPrice([]Tier{{Min: 1, Price: 0}}, 5); ok {
+		t.Error("tierPrice() matched a tier without price")
+	}
+	if _, ok := tierPrice(nil, 5); ok {
+		t.Error("tierPrice() matched without tiers")
+	}
+}
+
+func TestPaymentFees(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		t.Setenv("PAYMENT_FEES", "")
+		fees := PaymentFees()
+		for method, want := range map[string]int{"atm": 15, "ibon": 30, "famiport": 30} {
+			if fees[method] != want {
+				t.Errorf("fee %s = %d, want %d", method, fees[method], want)
+			}
+		}
+		fees["atm"] = 0
+		if PaymentFees()["atm"] != 15 {
+			t.Error("modifying returned fees changed the defaults")
+		}
+	})
+	t.Run("override", func(t *testing.T) {
+		t.Setenv("PAYMENT_FEES", `{"atm":10}`)
+		fees := PaymentFees()
+		if len(fees) != 1 || fees["atm"] != 10 {
+			t.Errorf("PaymentFees() = %v, want only atm 10", fees)
+		}
+	})
+	t.Run("invalid override falls back to defaults", func(t *testing.T) {
+		t.Setenv("PAYMENT_FEES", `{"atm":`)
+		if fees := PaymentFees(); fees["atm"] != 15 || fees["ibon"] != 30 {
+			t.Errorf("PaymentFees() = %v, want defaults", fees)
+		}
+	})
+}
+
+func TestBuildQuote(t *testing.T) {
+	fees := map[string]int{"atm": 15, "ibon": 30, "famiport": 30}
+	tests := []struct {
+		method  string
+		wantFee int
+	}{
+		{"", 0},
+		{"atm", 15},
+		{"ibon", 30},
+		{"famiport", 30},
+	}
+	for _, tt := range tests {
+		q := buildQuote("q1", "76561198000000001", "key", 10, 95, fees, tt.method)
+		if q.Subtotal != 950 || q.Fee != tt.wantFee || q.Total != 950+tt.wantFee {
+			t.Errorf("buildQuote(%q) subtotal=%d fee=%d total=%d, want 950, %d, %d", tt.method, q.Subtotal, q.Fee, q.Total, tt.wantFee, 950+tt.wantFee)
+		}
+		if q.ExpiresAt.IsZero() {
+			t.Errorf("buildQuote(%q) without expiry", tt.method)
+		}
+	}
+}
+
+// useTestRedis 連接 REDIS_TEST_URL，未設定時略過測試
+func useTestRedis(t *testing.T) {
+	t.Helper()
+	redisURL := os.Getenv("REDIS_TEST_URL")
+	if redisURL == "" {
+		t.Skip("REDIS_TEST_URL not set")
+	}
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := model.RedisClient
+	model.RedisClient = redis.NewClient(opt)
+	t.Cleanup(func() {
+		model.RedisClient.Close()
+		model.RedisClient = previous
+	})
+}
+
+func TestClaimQuote(t *testing.T) {
+	useTestRedis(t)
+	ctx := context.Background()
+
+	product := catalog.Product{ID: "test", TiersKey: "TEST_TIERS_CLAIM", PriceKey: "TEST_PRICE_CLAIM"}
+	if err := model.RedisClient.Set(ctx, product.PriceKey, 100, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { model.RedisClient.Del(ctx, product.PriceKey) })
+
+	quote, err := NewQuote(ctx, "76561198000000001", product, 3, "ATM")
+	if err != nil {
+		t.Fatalf("NewQuote() error = %v", err)
+	}
+	t.Cleanup(func() { model.RedisClient.Del(ctx, "QUOTE:"+quote.ID, "QUOTE_CLAIM:"+quote.ID) })
+	if quote.Method != "atm" || quote.Total != 315 {
+		t.Errorf("NewQuote() method=%q total=%d, want atm 315", quote.Method, quote.Total)
+	}
+
+	if _, err := ClaimQuote(ctx, quote.ID, "76561198000000002"); err != ErrQuoteNotFound {
+		t.Errorf("ClaimQuote() by another user error = %v, want ErrQuoteNotFound", err)
+	}
+
+	claim, err := ClaimQuote(ctx, quote.ID, quote.SteamID)
+	if err != nil {
+		t.Fatalf("ClaimQuote() error = %v", err)
+	}
+	if _, err := ClaimQuote(ctx, quote.ID, quote.SteamID); err != ErrQuoteInUse {
+		t.Errorf("ClaimQuote() while claimed error = %v, want ErrQuoteInUse", err)
+	}
+
+	// 解除鎖定後報價可再使用
+	claim.Release()
+	claim, err = ClaimQuote(ctx, quote.ID, quote.SteamID)
+	if err != nil {
+		t.Fatalf("ClaimQuote() after release error = %v", err)
+	}
+	if err := claim.Redeem(ctx); err != nil {
+		t.Fatalf("Redeem() error = %v", err)
+	}
+	if _, err := ClaimQuote(ctx, quote.ID, quote.SteamID); err != ErrQuoteNotFound {
+		t.Errorf("ClaimQuote() after redeem error = %v, want ErrQuoteNotFound", err)
+	}
+	if err := claim.Redeem(ctx); err != ErrQuoteNotFound {
+		t.Errorf("Redeem() twice error = %v, want ErrQuoteNotFound", err)
+	}
+
+	if _, err := NewQuote(ctx, quote.SteamID, product, 3, "cash"); err != ErrInvalidMethod {
+		t.Errorf("NewQuote() with unknown method error = %v, want ErrInvalidMethod", err)
+	}
+}
@@ -0,0 +1,175 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"yt-api/internal/model"
+	"yt-api/internal/steam"
+	"yt-api/internal/types"
+	"yt-api/internal/utils"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// maxBatchSize 為 GetPlayerSummaries 單次可查詢的 Steam ID 上限
+const maxBatchSize = 100
+
+var ErrProfileNotFound = errors.New("steam profile not found")
+
+var (
+	cacheTTL   = utils.GetEnvDuration("PROFILE_CACHE_TTL", 24*time.Hour)
+	refreshAge = utils.GetEnvDuration("PROFILE_REFRESH_AGE", time.Hour)
+	// fetchTimeout 為共用查詢的逾時，不受個別呼叫端的 ctx 影響
+	fetchTimeout = utils.GetEnvDuration("PROFILE_FETCH_TIMEOUT", 30*time.Second)
+	group        singleflight.Group
+)
+
+// cachedPlayer 為存在 Redis 中的用戶資料
+type cachedPlayer struct {
+	Player    types.Player `json:"player"`
+	FetchedAt time.Time    `json:"fetchedAt"`
+}
+
+func cacheKey(steamID string) string {
+	return "PROFILE:" + steamID
+}
+
+// Get 取得單一 Steam 用戶資料
+func Get(ctx context.Context, steamID string) (*types.Player, error) {
+	players, err := GetMany(ctx, []string{steamID})
+	if err != nil {
+		return nil, err
+	}
+	player, ok := players[steamID]
+	if !ok {
+		return nil, ErrProfileNotFound
+	}
+	return &player, nil
+}
+
+// GetMany 取得多個 Steam 用戶資料，優先使用 Redis 快取，
+// 快取超過 PROFILE_REFRESH_AGE 時先回傳舊資料並在背景更新
+func GetMany(ctx context.Context, steamIDs []string) (map[string]types.Player, error) {
+	players := make(map[string]types.Player, len(steamIDs))
+	if len(steamIDs) == 0 {
+		return players, nil
+	}
+
+	keys := make([]string, len(steamIDs))
+	for i, id := range steamIDs {
+		keys[i] = cacheKey(id)
+	}
+	values, err := model.RedisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		log.Println("Error reading profile cache:", err)
+		values = make([]interface{}, len(steamIDs))
+	}
+
+	var missing, stale []string
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			missing = append(missing, steamIDs[i])
+			continue
+		}
+		var cached cachedPlayer
+		if err := json.Unmarshal([]byte(raw), &cached); err != nil {
+			missing = append(missing, steamIDs[i])
+			continue
+		}
+		players[steamIDs[i]] = cached.Player
+		if time.Since(cached.FetchedAt) > refreshAge {
+			stale = append(stale, steamIDs[i])
+		}
+	}
+
+	if len(stale) > 0 {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if _, err := fetch(ctx, stale); err != nil {
+				log.Println("Error refreshing Steam profiles:", err)
+			}
+		}()
+	}
+
+	fetched, err := fetch(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for id, player := range fetched {
+		players[id] = player
+	}
+	return players, nil
+}
+
+// fetch 以每批最多 100 個 ID 向 Steam 查詢並寫入快取，相同批次的並發請求只會查詢一次
+func fetch(ctx context.Context, steamIDs []string) (map[string]types.Player, error) {
+	players := make(map[string]types.Player, len(steamIDs))
+	ids := append([]string(nil), steamIDs...)
+	sort.Strings(ids)
+
+	for start := 0; start < len(ids); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := strings.Join(ids[start:end], ",")
+
+		// 查詢與呼叫端的 ctx 分開，避免第一個呼叫端取消時其他等待中的呼叫端一起失敗
+		ch := group.DoChan(batch, func() (interface{}, error) {
+			fetchCtx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+			defer cancel()
+			return fetchBatch(fetchCtx, batch)
+		})
+		var result singleflight.Result
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case result = <-ch:
+		}
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		for _, player := range result.Val.([]types.Player) {
+			players[player.SteamID] = player
+		}
+	}
+	return players, nil
+}
+
+// fetchBatch 呼叫 GetPlayerSummaries 並寫入快取
+func fetchBatch(ctx context.Context, batch string) ([]types.Player, error) {
+	url := fmt.Sprintf("%s/ISteamUser/GetPlayerSummaries/v0002/?key=%s&steamids=%s", steam.APIURL, steam.APIKey, batch)
+
+	var profile types.ProfileResponse
+	if err := steam.Default.GetJSON(ctx, url, &profile); err != nil {
+		log.Println("Error occurred while getting profile from steam:", err)
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err := model.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, player := range profile.Response.Players {
+			data, err := json.Marshal(cachedPlayer{Player: player, FetchedAt: now})
+			if err != nil {
+				return err
+			}
+			pipe.Set(ctx, cacheKey(player.SteamID), data, cacheTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Error writing profile cache:", err)
+	}
+
+	return profile.Response.Players, nil
+}
@@ -0,0 +1,161 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"yt-api/internal/model"
+	"yt-api/internal/steam"
+	"yt-api/internal/types"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// useFakeSteam 將 Steam API 指向 handler，Redis 指向無法連線的位址，所有查詢都不會命中快取
+func useFakeSteam(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+
+	previousURL, previousClient, previousRedis := steam.APIURL, steam.Default, model.RedisClient
+	steam.APIURL = server.URL
+	steam.Default = steam.NewClient(steam.Options{Timeout: 2 * time.Second, BaseBackoff: time.Millisecond})
+	model.RedisClient = redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 50 * time.Millisecond, MaxRetries: -1})
+
+	t.Cleanup(func() {
+		server.Close()
+		model.RedisClient.Close()
+		steam.APIURL, steam.Default, model.RedisClient = previousURL, previousClient, previousRedis
+	})
+}
+
+// writePlayers 回傳 steamids 參數中每個 ID 的用戶資料，略過 skip 中的 ID
+func writePlayers(w http.ResponseWriter, r *http.Request, skip map[string]bool) {
+	var profile types.ProfileResponse
+	for _, id := range strings.Split(r.URL.Query().Get("steamids"), ",") {
+		if !skip[id] {
+			profile.Response.Players = append(profile.Response.Players, types.Player{SteamID: id, PersonaName: "player " + id})
+		}
+	}
+	json.NewEncoder(w).Encode(profile)
+}
+
+func TestGetManyBatches(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]string
+	useFakeSteam(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		batches = append(batches, strings.Split(r.URL.Query().Get("steamids"), ","))
+		mu.Unlock()
+		writePlayers(w, r, map[string]bool{"76561198000000007": true})
+	})
+
+	ids := make([]string, 250)
+	for i := range ids {
+		ids[len(ids)-1-i] = strconv.FormatUint(76561198000000000+uint64(i), 10)
+	}
+	players, err := GetMany(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("GetMany() error = %v", err)
+	}
+
+	if len(batches) != 3 {
+		t.Fatalf("requests = %d, want 3", len(batches))
+	}
+	seen := 0
+	for _, batch := range batches {
+		if len(batch) > maxBatchSize {
+			t.Errorf("batch size = %d, want at most %d", len(batch), maxBatchSize)
+		}
+		if !sort.StringsAreSorted(batch) {
+			t.Errorf("batch not sorted: %v", batch)
+		}
+		seen += len(batch)
+	}
+	if seen != len(ids) {
+		t.Errorf("requested %d ids, want %d", seen, len(ids))
+	}
+
+	if len(players) != len(ids)-1 {
+		t.Errorf("players = %d, want %d", len(players), len(ids)-1)
+	}
+	if _, ok := players["76561198000000007"]; ok {
+		t.Errorf("players contains profile missing from response")
+	}
+	if got := players["76561198000000100"].PersonaName; got != "player 76561198000000100" {
+		t.Errorf("PersonaName = %q", got)
+	}
+}
+
+func TestGet(t *testing.T) {
+	useFakeSteam(t, func(w http.ResponseWriter, r *http.Request) {
+		writePlayers(w, r, map[string]bool{"76561198000000002": true})
+	})
+
+	player, err := Get(context.Background(), "76561198000000001")
+	if err != nil || player.SteamID != "76561198000000001" {
+		t.Errorf("Get() = %+v, %v", player, err)
+	}
+	if _, err := Get(context.Background(), "76561198000000002"); err != ErrProfileNotFound {
+		t.Errorf("Get() missing profile error = %v, want ErrProfileNotFound", err)
+	}
+}
+
+func TestGetManySharesFetch(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	release := make(chan struct{})
+	useFakeSteam(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		<-release
+		writePlayers(w, r, nil)
+	})
+	// 測試提前結束時也要放行查詢，否則關閉假伺服器時會卡住
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+	t.Cleanup(unblock)
+
+	ids := []string{"76561198000000002", "76561198000000001"}
+
+	// 第一個呼叫端取消時，共用同一次查詢的其他呼叫端不受影響
+	cancelled, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err := GetMany(cancelled, ids)
+		errs <- err
+	}()
+	var players map[string]types.Player
+	go func() {
+		var err error
+		players, err = GetMany(context.Background(), []string{ids[1], ids[0]})
+		errs <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("cancelled GetMany() error = %v, want context.Canceled", err)
+	}
+	unblock()
+	if err := <-errs; err != nil {
+		t.Fatalf("GetMany() error = %v", err)
+	}
+
+	if len(players) != 2 {
+		t.Errorf("players = %d, want 2", len(players))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+}
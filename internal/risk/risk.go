@@ -0,0 +1,169 @@
+package risk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"yt-api/internal/model"
+	"yt-api/internal/profile"
+	"yt-api/internal/steam"
+	"yt-api/internal/types"
+	"yt-api/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 人工審核狀態
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// Assessment 表示一筆訂單的風險評估結果，Hold 為 true 時需由管理員核准後才能出貨
+type Assessment struct {
+	Score      int       `bson:"Score" json:"score"`
+	Reasons    []string  `bson:"Reasons" json:"reasons"`
+	Hold       bool      `bson:"Hold" json:"hold"`
+	Status     string    `bson:"Status,omitempty" json:"status,omitempty"`
+	ReviewedBy string    `bson:"ReviewedBy,omitempty" json:"reviewedBy,omitempty"`
+	ReviewedAt time.Time `bson:"ReviewedAt,omitempty" json:"reviewedAt,omitempty"`
+	AssessedAt time.Time `bson:"AssessedAt" json:"assessedAt"`
+}
+
+var errBansNotFound = errors.New("player bans not found")
+
+var (
+	holdScore     = utils.GetEnvInt("RISK_HOLD_SCORE", 50)
+	velocityKeys  = utils.GetEnvInt("RISK_VELOCITY_KEYS", 50)
+	velocityCount = utils.GetEnvInt("RISK_VELOCITY_ORDERS", 5)
+)
+
+func (a *Assessment) add(points int, format string, args ...interface{}) {
+	a.Score += points
+	a.Reasons = append(a.Reasons, fmt.Sprintf(format, args...))
+}
+
+// Assess 依 Steam 封鎖紀錄、帳號年齡、個人檔案公開狀態與近期購買頻率計算風險分數，
+// count 為這次要購買的數量
+func Assess(ctx context.Context, steamID string, count int) *Assessment {
+	a := &Assessment{AssessedAt: time.Now()}
+
+	if bans, err := getPlayerBans(ctx, steamID); err != nil {
+		log.Printf("Error getting player bans for %s: %v", steamID, err)
+		a.add(holdScore, "無法取得封鎖紀錄")
+	} else {
+		a.scoreBans(bans)
+	}
+
+	if player, err := profile.Get(ctx, steamID); err != nil {
+		log.Printf("Error getting profile for %s: %v", steamID, err)
+		a.add(holdScore, "無法取得個人檔案")
+	} else {
+		a.scoreProfile(player, a.AssessedAt)
+	}
+
+	if orders, keys, err := recentPurchases(ctx, steamID); err != nil {
+		log.Printf("Error getting recent purchases for %s: %v", steamID, err)
+	} else {
+		a.scoreVelocity(orders, keys, count)
+	}
+
+	a.decide()
+	return a
+}
+
+// scoreBans 依 VAC、遊戲、社群與交易封鎖加分
+func (a *Assessment) scoreBans(bans *types.PlayerBans) {
+	if bans.VACBanned || bans.NumberOfGameBans > 0 {
+		a.add(25, "VAC/遊戲封鎖 %d/%d 次", bans.NumberOfVACBans, bans.NumberOfGameBans)
+	}
+	if bans.CommunityBanned {
+		a.add(30, "社群封鎖")
+	}
+	switch bans.EconomyBan {
+	case "probation":
+		a.add(20, "交易觀察期")
+	case "banned":
+		a.add(50, "交易封鎖")
+	}
+}
+
+// scoreProfile 依個人檔案公開狀態與帳號建立至 now 的時間加分
+func (a *Assessment) scoreProfile(player *types.Player, now time.Time) {
+	// communityvisibilitystate 為 3 表示公開
+	if player.CommunityVisibility != 3 {
+		a.add(15, "個人檔案未公開")
+	}
+	if player.TimeCreated == 0 {
+		a.add(15, "無法取得帳號建立時間")
+		return
+	}
+	age := now.Sub(time.Unix(int64(player.TimeCreated), 0))
+	switch {
+	case age < 30*24*time.Hour:
+		a.add(40, "帳號建立未滿 30 天")
+	case age < 180*24*time.Hour:
+		a.add(20, "帳號建立未滿 180 天")
+	}
+}
+
+// scoreVelocity 依 24 小時內的訂單數與數量加分，orders 與 keys 不含這次購買
+func (a *Assessment) scoreVelocity(orders, keys, count int) {
+	if orders+1 > velocityCount {
+		a.add(20, "24 小時內訂單 %d 筆", orders+1)
+	}
+	if keys+count > velocityKeys {
+		a.add(30, "24 小時內購買 %d 個", keys+count)
+	}
+}
+
+// decide 在分數達到 RISK_HOLD_SCORE 時暫停出貨並等待審核
+func (a *Assessment) decide() {
+	a.Hold = a.Score >= holdScore
+	if a.Hold {
+		a.Status = StatusPending
+	}
+}
+
+// getPlayerBans 呼叫 ISteamUser/GetPlayerBans
+func getPlayerBans(ctx context.Context, steamID string) (*types.PlayerBans, error) {
+	url := fmt.Sprintf("%s/ISteamUser/GetPlayerBans/v1/?key=%s&steamids=%s", steam.APIURL, steam.APIKey, steamID)
+
+	var result types.PlayerBansResponse
+	if err := steam.Default.GetJSON(ctx, url, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Players) == 0 {
+		return nil, errBansNotFound
+	}
+	return &result.Players[0], nil
+}
+
+// recentPurchases 回傳 24 小時內建立的訂單數與數量
+func recentPurchases(ctx context.Context, steamID string) (orders, keys int, err error) {
+	since := time.Now().Add(-24 * time.Hour).In(utils.Taipei).Format("20060102150405")
+	cursor, err := model.Db.Collection("orderv2").Find(ctx, bson.M{
+		"SteamID":             steamID,
+		"OrderStatus.Data_id": bson.M{"$gte": since},
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var order struct {
+			Count int `bson:"Count"`
+		}
+		if err := cursor.Decode(&order); err != nil {
+			return 0, 0, err
+		}
+		orders++
+		keys += order.Count
+	}
+	return orders, keys, cursor.Err()
+}
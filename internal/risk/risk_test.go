@@ -0,0 +1,153 @@
+package risk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"yt-api/internal/steam"
+	"yt-api/internal/types"
+)
+
+func TestScoreBans(t *testing.T) {
+	tests := []struct {
+		name    string
+		bans    types.PlayerBans
+		want    int
+		reasons int
+	}{
+		{"clean", types.PlayerBans{EconomyBan: "none"}, 0, 0},
+		{"vac banned", types.PlayerBans{VACBanned: true, NumberOfVACBans: 1}, 25, 1},
+		{"game bans only", types.PlayerBans{NumberOfGameBans: 2}, 25, 1},
+		{"community banned", types.PlayerBans{CommunityBanned: true}, 30, 1},
+		{"trade probation", types.PlayerBans{EconomyBan: "probation"}, 20, 1},
+		{"trade banned", types.PlayerBans{EconomyBan: "banned"}, 50, 1},
+		{"everything", types.PlayerBans{VACBanned: true, CommunityBanned: true, EconomyBan: "banned"}, 105, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Assessment{}
+			a.scoreBans(&tt.bans)
+			if a.Score != tt.want || len(a.Reasons) != tt.reasons {
+				t.Errorf("scoreBans() = %d %v, want %d with %d reasons", a.Score, a.Reasons, tt.want, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestScoreProfile(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	created := func(age time.Duration) int {
+		return int(now.Add(-age).Unix())
+	}
+	tests := []struct {
+		name   string
+		player types.Player
+		want   int
+	}{
+		{"public old account", types.Player{CommunityVisibility: 3, TimeCreated: created(365 * 24 * time.Hour)}, 0},
+		{"private old account", types.Player{CommunityVisibility: 1, TimeCreated: created(365 * 24 * time.Hour)}, 15},
+		{"hidden creation time", types.Player{CommunityVisibility: 3}, 15},
+		{"private without creation time", types.Player{CommunityVisibility: 1}, 30},
+		{"under 30 days", types.Player{CommunityVisibility: 3, TimeCreated: created(29 * 24 * time.Hour)}, 40},
+		{"exactly 30 days", types.Player{CommunityVisibility: 3, TimeCreated: created(30 * 24 * time.Hour)}, 20},
+		{"under 180 days", types.Player{CommunityVisibility: 3, TimeCreated: created(179 * 24 * time.Hour)}, 20},
+		{"exactly 180 days", types.Player{CommunityVisibility: 3, TimeCreated: created(180 * 24 * time.Hour)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Assessment{}
+			a.scoreProfile(&tt.player, now)
+			if a.Score != tt.want {
+				t.Errorf("scoreProfile() = %d %v, want %d", a.Score, a.Reasons, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreVelocity(t *testing.T) {
+	defer func(c, k int) { velocityCount, velocityKeys = c, k }(velocityCount, velocityKeys)
+	velocityCount, velocityKeys = 5, 50
+
+	tests := []struct {
+		name   string
+		orders int
+		keys   int
+		count  int
+		want   int
+	}{
+		{"first order", 0, 0, 10, 0},
+		{"at order limit", 4, 20, 10, 0},
+		{"over order limit", 5, 20, 10, 20},
+		{"at key limit", 1, 40, 10, 0},
+		{"over key limit", 1, 40, 11, 30},
+		{"single large order", 0, 0, 51, 30},
+		{"both limits", 5, 45, 10, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Assessment{}
+			a.scoreVelocity(tt.orders, tt.keys, tt.count)
+			if a.Score != tt.want {
+				t.Errorf("scoreVelocity(%d, %d, %d) = %d %v, want %d", tt.orders, tt.keys, tt.count, a.Score, a.Reasons, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	defer func(v int) { holdScore = v }(holdScore)
+	holdScore = 50
+
+	tests := []struct {
+		score      int
+		wantHold   bool
+		wantStatus string
+	}{
+		{0, false, ""},
+		{49, false, ""},
+		{50, true, StatusPending},
+		{105, true, StatusPending},
+	}
+	for _, tt := range tests {
+		a := &Assessment{Score: tt.score}
+		a.decide()
+		if a.Hold != tt.wantHold || a.Status != tt.wantStatus {
+			t.Errorf("decide() score %d = %v %q, want %v %q", tt.score, a.Hold, a.Status, tt.wantHold, tt.wantStatus)
+		}
+	}
+}
+
+func TestGetPlayerBans(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ISteamUser/GetPlayerBans/v1/" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("steamids") == "76561198000000001" {
+			w.Write([]byte(`{"players":[{"SteamId":"76561198000000001","CommunityBanned":false,"VACBanned":true,"NumberOfVACBans":2,"NumberOfGameBans":0,"EconomyBan":"probation"}]}`))
+			return
+		}
+		w.Write([]byte(`{"players":[]}`))
+	}))
+	defer server.Close()
+
+	previousURL, previousClient := steam.APIURL, steam.Default
+	steam.APIURL = server.URL
+	steam.Default = steam.NewClient(steam.Options{Timeout: 2 * time.Second, BaseBackoff: time.Millisecond})
+	defer func() { steam.APIURL, steam.Default = previousURL, previousClient }()
+
+	bans, err := getPlayerBans(context.Background(), "76561198000000001")
+	if err != nil {
+		t.Fatalf("getPlayerBans() error = %v", err)
+	}
+	if !bans.VACBanned || bans.NumberOfVACBans != 2 || bans.EconomyBan != "probation" {
+		t.Errorf("getPlayerBans() = %+v", bans)
+	}
+
+	if _, err := getPlayerBans(context.Background(), "76561198000000002"); err != errBansNotFound {
+		t.Errorf("getPlayerBans() unknown player error = %v, want errBansNotFound", err)
+	}
+}
@@ -0,0 +1,257 @@
+package steam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"yt-api/internal/utils"
+)
+
+var (
+	// ErrCircuitOpen 表示 Steam 近期連續失敗，暫時不再送出請求
+	ErrCircuitOpen = errors.New("steam: circuit open")
+	// ErrRateLimited 表示 Steam 回應 429 且重試後仍未成功
+	ErrRateLimited = errors.New("steam: rate limited")
+)
+
+// StatusError 表示 Steam 回應非 2xx 的狀態碼
+type StatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("steam: %s returned status %d", e.URL, e.StatusCode)
+}
+
+// IsUnavailable 判斷錯誤是否代表 Steam 暫時無法使用，呼叫端應降級處理
+func IsUnavailable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return err != nil && !errors.Is(err, context.Canceled)
+}
+
+// Options 為 Client 的設定
+type Options struct {
+	Timeout          time.Duration
+	MaxRetries       int
+	BaseBackoff      time.Duration
+	RequestsPerSec   float64
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+// Client 為共用的 Steam Web API client，包含逾時、重試、限流與斷路器，
+// 限流與斷路器依端點類別 (host 加上第一段路徑，例如 steamcommunity.com/market、steamcommunity.com/openid) 分開計算，
+// 市場查詢被限流時不會影響同一個 host 上的登入驗證
+type Client struct {
+	http      *http.Client
+	opts      Options
+	mu        sync.Mutex
+	endpoints map[string]*endpointState
+}
+
+type endpointState struct {
+	mu        sync.Mutex
+	tokens    float64
+	lastFill  time.Time
+	failures  int
+	openUntil time.Time
+}
+
+// Default 為服務共用的 Steam client，設定可由環境變數調整
+var Default = NewClient(Options{
+	Timeout:          utils.GetEnvDuration("STEAM_TIMEOUT", 10*time.Second),
+	MaxRetries:       utils.GetEnvInt("STEAM_MAX_RETRIES", 3),
+	BaseBackoff:      utils.GetEnvDuration("STEAM_BACKOFF", 500*time.Millisecond),
+	RequestsPerSec:   float64(utils.GetEnvInt("STEAM_RATE_LIMIT", 5)),
+	FailureThreshold: utils.GetEnvInt("STEAM_BREAKER_THRESHOLD", 5),
+	Cooldown:         utils.GetEnvDuration("STEAM_BREAKER_COOLDOWN", 30*time.Second),
+})
+
+// NewClient 建立 Steam client
+func NewClient(opts Options) *Client {
+	return &Client{
+		http:      &http.Client{Timeout: opts.Timeout},
+		opts:      opts,
+		endpoints: map[string]*endpointState{},
+	}
+}
+
+// endpointClass 回傳 URL 的端點類別：host 加上第一段路徑
+func endpointClass(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	segment := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)[0]
+	return u.Host + "/" + segment
+}
+
+func (c *Client) endpoint(rawURL string) *endpointState {
+	key := endpointClass(rawURL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, ok := c.endpoints[key]
+	if !ok {
+		state = &endpointState{tokens: c.opts.RequestsPerSec, lastFill: time.Now()}
+		c.endpoints[key] = state
+	}
+	return state
+}
+
+// wait 依 token bucket 限流，必要時等待下一個 token
+func (c *Client) wait(ctx context.Context, state *endpointState) error {
+	if c.opts.RequestsPerSec <= 0 {
+		return nil
+	}
+	for {
+		state.mu.Lock()
+		now := time.Now()
+		state.tokens += now.Sub(state.lastFill).Seconds() * c.opts.RequestsPerSec
+		if state.tokens > c.opts.RequestsPerSec {
+			state.tokens = c.opts.RequestsPerSec
+		}
+		state.lastFill = now
+		if state.tokens >= 1 {
+			state.tokens--
+			state.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - state.tokens) / c.opts.RequestsPerSec * float64(time.Second))
+		state.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *Client) allow(state *endpointState) bool {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return time.Now().After(state.openUntil)
+}
+
+func (c *Client) record(state *endpointState, ok bool) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if ok {
+		state.failures = 0
+		return
+	}
+	state.failures++
+	if c.opts.FailureThreshold > 0 && state.failures >= c.opts.FailureThreshold {
+		state.openUntil = time.Now().Add(c.opts.Cooldown)
+		log.Printf("Steam circuit opened for %s after %d failures", c.opts.Cooldown, state.failures)
+	}
+}
+
+// backoff 回傳第 attempt 次重試前的等待時間 (指數退避加上隨機抖動)
+func (c *Client) backoff(attempt int) time.Duration {
+	base := c.opts.BaseBackoff << attempt
+	return base/2 + time.Duration(rand.Int63n(int64(base/2)+1))
+}
+
+// Do 送出請求並讀取回應內容，429 與 5xx 會重試
+func (c *Client) Do(ctx context.Context, method, rawURL string, body string, contentType string) ([]byte, error) {
+	state := c.endpoint(rawURL)
+	if !c.allow(state) {
+		return nil, ErrCircuitOpen
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.opts.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(c.backoff(attempt - 1)):
+			}
+		}
+		if err := c.wait(ctx, state); err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, rawURL, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			lastErr = &StatusError{StatusCode: resp.StatusCode, URL: req.URL.Path}
+			continue
+		}
+		c.record(state, true)
+		if resp.StatusCode >= 400 {
+			return nil, &StatusError{StatusCode: resp.StatusCode, URL: req.URL.Path}
+		}
+		return data, nil
+	}
+
+	c.record(state, false)
+	var statusErr *StatusError
+	if errors.As(lastErr, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests {
+		return nil, ErrRateLimited
+	}
+	return nil, lastErr
+}
+
+// Get 送出 GET 請求
+func (c *Client) Get(ctx context.Context, rawURL string) ([]byte, error) {
+	return c.Do(ctx, http.MethodGet, rawURL, "", "")
+}
+
+// GetJSON 送出 GET 請求並解析 JSON 回應
+func (c *Client) GetJSON(ctx context.Context, rawURL string, v interface{}) error {
+	data, err := c.Get(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// PostForm 送出表單 POST 請求
+func (c *Client) PostForm(ctx context.Context, rawURL string, values url.Values) ([]byte, error) {
+	return c.Do(ctx, http.MethodPost, rawURL, values.Encode(), "application/x-www-form-urlencoded")
+}
+
+// APIURL 為 Steam Web API 的位址，測試時可指向本機的假伺服器
+var APIURL = utils.GetEnv("STEAM_API_URL", "https://api.steampowered.com")
+
+// APIKey 為 Steam Web API 金鑰
+var APIKey = os.Getenv("STEAM_API_KEY")
@@ -0,0 +1,112 @@
+package steam
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useTestClient 以不限流、快速重試的 client 取代 Default，測試結束後還原
+func useTestClient(t *testing.T, opts Options) *Client {
+	t.Helper()
+	if opts.Timeout == 0 {
+		opts.Timeout = 2 * time.Second
+	}
+	if opts.BaseBackoff == 0 {
+		opts.BaseBackoff = time.Millisecond
+	}
+	previous := Default
+	Default = NewClient(opts)
+	t.Cleanup(func() { Default = previous })
+	return Default
+}
+
+func TestEndpointClass(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://steamcommunity.com/market/itemordershistogram?item_nameid=1", "steamcommunity.com/market"},
+		{"https://steamcommunity.com/openid/login", "steamcommunity.com/openid"},
+		{"https://steamcommunity.com/inventory/7656/440/2", "steamcommunity.com/inventory"},
+		{"https://api.steampowered.com/ISteamUser/GetPlayerSummaries/v2/", "api.steampowered.com/ISteamUser"},
+		{"https://api.steampowered.com", "api.steampowered.com/"},
+	}
+	for _, tt := range tests {
+		if got := endpointClass(tt.url); got != tt.want {
+			t.Errorf("endpointClass(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestClientRetriesAndBreaker(t *testing.T) {
+	var marketCalls, openidCalls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/market/fail":
+			atomic.AddInt32(&marketCalls, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/market/limited":
+			if atomic.AddInt32(&marketCalls, 1) == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Write([]byte("ok"))
+		case "/openid/login":
+			atomic.AddInt32(&openidCalls, 1)
+			w.Write([]byte("ok"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	t.Run("retries 429 then succeeds", func(t *testing.T) {
+		c := NewClient(Options{Timeout: 2 * time.Second, MaxRetries: 2, BaseBackoff: time.Millisecond})
+		atomic.StoreInt32(&marketCalls, 0)
+		data, err := c.Get(ctx, srv.URL+"/market/limited")
+		if err != nil || string(data) != "ok" {
+			t.Fatalf("Get() = %q, %v", data, err)
+		}
+		if n := atomic.LoadInt32(&marketCalls); n != 2 {
+			t.Errorf("calls = %d, want 2", n)
+		}
+	})
+
+	t.Run("4xx is not retried", func(t *testing.T) {
+		c := NewClient(Options{Timeout: 2 * time.Second, MaxRetries: 2, BaseBackoff: time.Millisecond})
+		_, err := c.Get(ctx, srv.URL+"/unknown")
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+			t.Fatalf("Get() error = %v, want 404 StatusError", err)
+		}
+		if IsUnavailable(err) {
+			t.Error("IsUnavailable(404) = true, want false")
+		}
+	})
+
+	t.Run("breaker opens per endpoint class", func(t *testing.T) {
+		c := NewClient(Options{Timeout: 2 * time.Second, BaseBackoff: time.Millisecond, FailureThreshold: 2, Cooldown: time.Minute})
+		atomic.StoreInt32(&marketCalls, 0)
+		for i := 0; i < 2; i++ {
+			if _, err := c.Get(ctx, srv.URL+"/market/fail"); !IsUnavailable(err) {
+				t.Fatalf("Get() error = %v, want unavailable", err)
+			}
+		}
+		if _, err := c.Get(ctx, srv.URL+"/market/fail"); err != ErrCircuitOpen {
+			t.Fatalf("Get() error = %v, want ErrCircuitOpen", err)
+		}
+		if n := atomic.LoadInt32(&marketCalls); n != 2 {
+			t.Errorf("market calls = %d, want 2", n)
+		}
+		if _, err := c.Get(ctx, srv.URL+"/openid/login"); err != nil {
+			t.Fatalf("openid Get() error = %v, want nil while market circuit is open", err)
+		}
+	})
+}
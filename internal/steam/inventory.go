@@ -0,0 +1,62 @@
+package steam
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"yt-api/internal/types"
+	"yt-api/internal/utils"
+)
+
+// InventoryURL 為 Steam 庫存的位址，測試時可指向本機的假伺服器
+var InventoryURL = utils.GetEnv("STEAM_INVENTORY_URL", "https://steamcommunity.com/inventory")
+
+const (
+	inventoryPageSize = 2000
+	// inventoryMaxPages 避免 last_assetid 沒有前進時無限分頁
+	inventoryMaxPages = 100
+)
+
+// GetInventoryPage 取得一頁庫存，startAssetID 為上一頁回傳的 last_assetid
+func GetInventoryPage(ctx context.Context, steamID string, appID int, contextID, startAssetID string) (*types.Inventory, error) {
+	rawURL := fmt.Sprintf("%s/%s/%d/%s?l=english&count=%d", InventoryURL, steamID, appID, contextID, inventoryPageSize)
+	if startAssetID != "" {
+		rawURL += "&start_assetid=" + url.QueryEscape(startAssetID)
+	}
+
+	var inventory types.Inventory
+	if err := Default.GetJSON(ctx, rawURL, &inventory); err != nil {
+		return nil, err
+	}
+	if inventory.Success != 1 {
+		return nil, fmt.Errorf("inventory of %s: success = %d", steamID, inventory.Success)
+	}
+	return &inventory, nil
+}
+
+// GetInventory 依 more_items 與 last_assetid 取得所有分頁，合併成一份庫存
+func GetInventory(ctx context.Context, steamID string, appID int, contextID string) (*types.Inventory, error) {
+	inventory, err := GetInventoryPage(ctx, steamID, appID, contextID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for pages := 1; inventory.More_items == 1; pages++ {
+		if pages >= inventoryMaxPages {
+			return nil, fmt.Errorf("inventory of %s: more than %d pages", steamID, inventoryMaxPages)
+		}
+		page, err := GetInventoryPage(ctx, steamID, appID, contextID, inventory.Last_assetid)
+		if err != nil {
+			return nil, err
+		}
+		if page.Last_assetid == inventory.Last_assetid && page.More_items == 1 {
+			return nil, fmt.Errorf("inventory of %s: last_assetid %s did not advance", steamID, page.Last_assetid)
+		}
+		inventory.Assets = append(inventory.Assets, page.Assets...)
+		inventory.Descriptions = append(inventory.Descriptions, page.Descriptions...)
+		inventory.More_items = page.More_items
+		inventory.Last_assetid = page.Last_assetid
+	}
+	return inventory, nil
+}
@@ -0,0 +1,93 @@
+package steam
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetInventory(t *testing.T) {
+	tests := []struct {
+		name    string
+		pages   map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "single page",
+			pages: map[string]string{
+				"": `{"success":1,"more_items":0,"assets":[{"assetid":"1"},{"assetid":"2"}]}`,
+			},
+			want: []string{"1", "2"},
+		},
+		{
+			name: "follows last_assetid",
+			pages: map[string]string{
+				"":  `{"success":1,"more_items":1,"last_assetid":"2","assets":[{"assetid":"1"},{"assetid":"2"}]}`,
+				"2": `{"success":1,"more_items":1,"last_assetid":"3","assets":[{"assetid":"3"}]}`,
+				"3": `{"success":1,"more_items":0,"assets":[{"assetid":"4"}]}`,
+			},
+			want: []string{"1", "2", "3", "4"},
+		},
+		{
+			name: "last_assetid does not advance",
+			pages: map[string]string{
+				"":  `{"success":1,"more_items":1,"last_assetid":"2","assets":[{"assetid":"1"}]}`,
+				"2": `{"success":1,"more_items":1,"last_assetid":"2","assets":[{"assetid":"2"}]}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsuccessful response",
+			pages: map[string]string{
+				"": `{"success":0}`,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/76561198000000000/440/2" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				page, ok := tt.pages[r.URL.Query().Get("start_assetid")]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(page))
+			}))
+			defer srv.Close()
+
+			useTestClient(t, Options{})
+			previous := InventoryURL
+			InventoryURL = srv.URL
+			defer func() { InventoryURL = previous }()
+
+			inv, err := GetInventory(context.Background(), "76561198000000000", 440, "2")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("GetInventory() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(inv.Assets) != len(tt.want) {
+				t.Fatalf("GetInventory() returned %d assets, want %d", len(inv.Assets), len(tt.want))
+			}
+			for i, a := range inv.Assets {
+				if a.Assetid != tt.want[i] {
+					t.Errorf("asset %d = %s, want %s", i, a.Assetid, tt.want[i])
+				}
+			}
+			if inv.More_items != 0 {
+				t.Errorf("More_items = %d, want 0", inv.More_items)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package steam
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"yt-api/internal/types"
+	"yt-api/internal/utils"
+)
+
+// MarketCurrency 表示 Steam 市場的幣別設定
+type MarketCurrency struct {
+	Code     string
+	ID       int
+	Country  string
+	Language string
+}
+
+// MarketSource 表示一組要查詢的市場物品與幣別
+type MarketSource struct {
+	ItemNameID int
+	Currency   MarketCurrency
+}
+
+// marketCurrencies 對應 Steam 市場的 currency 代碼
+var marketCurrencies = map[string]MarketCurrency{
+	"USD": {Code: "USD", ID: 1, Country: "US", Language: "english"},
+	"GBP": {Code: "GBP", ID: 2, Country: "GB", Language: "english"},
+	"EUR": {Code: "EUR", ID: 3, Country: "DE", Language: "english"},
+	"JPY": {Code: "JPY", ID: 8, Country: "JP", Language: "japanese"},
+	"MYR": {Code: "MYR", ID: 11, Country: "MY", Language: "english"},
+	"SGD": {Code: "SGD", ID: 13, Country: "SG", Language: "english"},
+	"KRW": {Code: "KRW", ID: 16, Country: "KR", Language: "koreana"},
+	"CNY": {Code: "CNY", ID: 23, Country: "CN", Language: "schinese"},
+	"HKD": {Code: "HKD", ID: 29, Country: "HK", Language: "tchinese"},
+	"TWD": {Code: "TWD", ID: 30, Country: "TW", Language: "tchinese"},
+}
+
+var marketBaseURL = utils.GetEnv("STEAM_MARKET_URL", "https://steamcommunity.com/market")
+
+// MarketSources 從 STEAM_MARKET_ITEMS 與 STEAM_MARKET_CURRENCIES 解析要查詢的市場來源，
+// extraItems 會附加在設定的物品之後。第一個物品與第一個幣別為主要來源
+func MarketSources(extraItems ...int) []MarketSource {
+	var items []int
+	seen := map[int]bool{}
+	for _, s := range strings.Split(utils.GetEnv("STEAM_MARKET_ITEMS", "1"), ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			log.Printf("Invalid item_nameid %q in STEAM_MARKET_ITEMS", s)
+			continue
+		}
+		if !seen[id] {
+			seen[id] = true
+			items = append(items, id)
+		}
+	}
+	for _, id := range extraItems {
+		if !seen[id] {
+			seen[id] = true
+			items = append(items, id)
+		}
+	}
+
+	var currencies []MarketCurrency
+	for _, s := range strings.Split(utils.GetEnv("STEAM_MARKET_CURRENCIES", "TWD"), ",") {
+		currency, ok := marketCurrencies[strings.ToUpper(strings.TrimSpace(s))]
+		if !ok {
+			log.Printf("Unsupported currency %q in STEAM_MARKET_CURRENCIES", s)
+			continue
+		}
+		currencies = append(currencies, currency)
+	}
+
+	var sources []MarketSource
+	for _, item := range items {
+		for _, currency := range currencies {
+			sources = append(sources, MarketSource{ItemNameID: item, Currency: currency})
+		}
+	}
+	return sources
+}
+
+// GetMarketPrice 取得指定物品在指定幣別下的最低售價 (以分為單位)
+func GetMarketPrice(ctx context.Context, source MarketSource) (int, error) {
+	url := fmt.Sprintf("%s/itemordershistogram?country=%s&language=%s&currency=%d&item_nameid=%d&two_factor=0",
+		marketBaseURL, source.Currency.Country, source.Currency.Language, source.Currency.ID, source.ItemNameID)
+
+	var item types.MarketItem
+	if err := Default.GetJSON(ctx, url, &item); err != nil {
+		log.Println("Error occurred while getting market price:", err)
+		return 0, err
+	}
+
+	price, err := strconv.Atoi(item.LowestSellOrder)
+	if err != nil {
+		log.Println("Error parsing lowest sell order:", err)
+		return 0, err
+	}
+
+	return price, nil
+}
+
+// LookupMarketCurrency 以幣別代碼 (例如 "TWD") 查詢 Steam 市場幣別設定
+func LookupMarketCurrency(code string) (MarketCurrency, bool) {
+	currency, ok := marketCurrencies[strings.ToUpper(code)]
+	return currency, ok
+}
@@ -0,0 +1,71 @@
+package steam
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestMarketSources(t *testing.T) {
+	tests := []struct {
+		name       string
+		items      string
+		currencies string
+		extra      []int
+		want       []string
+	}{
+		{"defaults", "1", "TWD", nil, []string{"1/TWD"}},
+		{"items by currencies", "1, 2", "twd,USD", nil, []string{"1/TWD", "1/USD", "2/TWD", "2/USD"}},
+		{"invalid entries skipped", "1,x", "TWD,XXX", nil, []string{"1/TWD"}},
+		{"extra items appended once", "1", "TWD", []int{5, 1, 5}, []string{"1/TWD", "5/TWD"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STEAM_MARKET_ITEMS", tt.items)
+			t.Setenv("STEAM_MARKET_CURRENCIES", tt.currencies)
+			sources := MarketSources(tt.extra...)
+			if len(sources) != len(tt.want) {
+				t.Fatalf("MarketSources() returned %d sources, want %d", len(sources), len(tt.want))
+			}
+			for i, s := range sources {
+				got := strconv.Itoa(s.ItemNameID) + "/" + s.Currency.Code
+				if got != tt.want[i] {
+					t.Errorf("source %d = %s, want %s", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetMarketPrice(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/itemordershistogram" || q.Get("currency") != "1" || q.Get("country") != "US" || q.Get("item_nameid") != "42" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"success":1,"lowest_sell_order":"235"}`))
+	}))
+	defer srv.Close()
+
+	useTestClient(t, Options{})
+	previous := marketBaseURL
+	marketBaseURL = srv.URL
+	defer func() { marketBaseURL = previous }()
+
+	usd, _ := LookupMarketCurrency("usd")
+	price, err := GetMarketPrice(context.Background(), MarketSource{ItemNameID: 42, Currency: usd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price != 235 {
+		t.Errorf("GetMarketPrice() = %d, want 235", price)
+	}
+
+	twd, _ := LookupMarketCurrency("TWD")
+	if _, err := GetMarketPrice(context.Background(), MarketSource{ItemNameID: 42, Currency: twd}); err == nil {
+		t.Error("GetMarketPrice() with unexpected query succeeded, want error")
+	}
+}
@@ -0,0 +1,156 @@
+package steamid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"yt-api/internal/steam"
+)
+
+// SteamID 為個人帳號的 SteamID64
+type SteamID uint64
+
+// base 為個人帳號 (universe 1, type 1, instance 1) 的 SteamID64 起始值
+const base SteamID = 76561197960265728
+
+var (
+	ErrInvalid        = errors.New("invalid steam id")
+	ErrVanityNotFound = errors.New("vanity url not found")
+)
+
+var (
+	steamID64Pattern = regexp.MustCompile(`^\d{17}$`)
+	steamID3Pattern  = regexp.MustCompile(`^\[U:1:(\d+)\]$`)
+	steam2Pattern    = regexp.MustCompile(`^STEAM_[0-5]:([01]):(\d+)$`)
+	vanityPattern    = regexp.MustCompile(`^[A-Za-z0-9_-]{2,32}$`)
+)
+
+// FromAccountID 由 32 位元的 account ID 轉換為 SteamID64
+func FromAccountID(accountID uint32) SteamID {
+	return base + SteamID(accountID)
+}
+
+// String 回傳 SteamID64 字串
+func (id SteamID) String() string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// AccountID 回傳 32 位元的 account ID
+func (id SteamID) AccountID() uint32 {
+	return uint32(id - base)
+}
+
+// SteamID3 回傳 [U:1:n] 格式
+func (id SteamID) SteamID3() string {
+	return fmt.Sprintf("[U:1:%d]", id.AccountID())
+}
+
+// Steam2 回傳 STEAM_0:x:y 格式
+func (id SteamID) Steam2() string {
+	accountID := id.AccountID()
+	return fmt.Sprintf("STEAM_0:%d:%d", accountID&1, accountID>>1)
+}
+
+// Parse 解析 SteamID64、SteamID3、STEAM_0:x:y 與 /profiles/ 網址，
+// /id/ 自訂網址需要呼叫 Resolve
+func Parse(input string) (SteamID, error) {
+	input = strings.TrimSpace(input)
+
+	if path, ok := profilePath(input); ok {
+		if strings.HasPrefix(path, "profiles/") {
+			return Parse(strings.TrimPrefix(path, "profiles/"))
+		}
+		return 0, ErrInvalid
+	}
+
+	if steamID64Pattern.MatchString(input) {
+		value, err := strconv.ParseUint(input, 10, 64)
+		if err != nil || SteamID(value) <= base || SteamID(value)-base > 0xFFFFFFFF {
+			return 0, ErrInvalid
+		}
+		return SteamID(value), nil
+	}
+
+	if m := steamID3Pattern.FindStringSubmatch(input); m != nil {
+		accountID, err := strconv.ParseUint(m[1], 10, 32)
+		if err != nil || accountID == 0 {
+			return 0, ErrInvalid
+		}
+		return FromAccountID(uint32(accountID)), nil
+	}
+
+	if m := steam2Pattern.FindStringSubmatch(strings.ToUpper(input)); m != nil {
+		y, _ := strconv.ParseUint(m[1], 10, 32)
+		z, err := strconv.ParseUint(m[2], 10, 31)
+		if err != nil || (z == 0 && y == 0) {
+			return 0, ErrInvalid
+		}
+		return FromAccountID(uint32(z<<1 | y)), nil
+	}
+
+	return 0, ErrInvalid
+}
+
+// Resolve 解析任何支援的格式，包含 steamcommunity.com/id/ 自訂網址
+func Resolve(ctx context.Context, input string) (SteamID, error) {
+	input = strings.TrimSpace(input)
+	path, ok := profilePath(input)
+	if !ok || !strings.HasPrefix(path, "id/") {
+		return Parse(input)
+	}
+
+	vanity := strings.TrimPrefix(path, "id/")
+	if !vanityPattern.MatchString(vanity) {
+		return 0, ErrInvalid
+	}
+	return ResolveVanityURL(ctx, vanity)
+}
+
+// ResolveVanityURL 呼叫 ISteamUser/ResolveVanityURL 將自訂網址轉換為 SteamID
+func ResolveVanityURL(ctx context.Context, vanity string) (SteamID, error) {
+	endpoint := fmt.Sprintf("%s/ISteamUser/ResolveVanityURL/v0001/?key=%s&vanityurl=%s",
+		steam.APIURL, steam.APIKey, url.QueryEscape(vanity))
+
+	var result struct {
+		Response struct {
+			SteamID string `json:"steamid"`
+			Success int    `json:"success"`
+		} `json:"response"`
+	}
+	if err := steam.Default.GetJSON(ctx, endpoint, &result); err != nil {
+		return 0, err
+	}
+	if result.Response.Success != 1 {
+		return 0, ErrVanityNotFound
+	}
+	return Parse(result.Response.SteamID)
+}
+
+// profilePath 取出 steamcommunity.com 網址中的 profiles/xxx 或 id/xxx 路徑
+func profilePath(input string) (string, bool) {
+	if !strings.Contains(input, "steamcommunity.com/") {
+		return "", false
+	}
+	if !strings.Contains(input, "://") {
+		input = "https://" + input
+	}
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", false
+	}
+	// 只接受 steamcommunity.com 與其子網域，evilsteamcommunity.com 之類的網域不可通過
+	host := strings.ToLower(u.Hostname())
+	if host != "steamcommunity.com" && !strings.HasSuffix(host, ".steamcommunity.com") {
+		return "", false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 {
+		return "", true
+	}
+	return parts[0] + "/" + parts[1], true
+}
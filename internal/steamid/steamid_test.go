@@ -0,0 +1,156 @@
+package steamid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"yt-api/internal/steam"
+)
+
+// gaben 為 account ID 22202 的 SteamID64
+const gaben SteamID = 76561197960287930
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    SteamID
+		wantErr bool
+	}{
+		{"76561197960287930", gaben, false},
+		{"  76561197960287930 ", gaben, false},
+		{"[U:1:22202]", gaben, false},
+		{"STEAM_0:0:11101", gaben, false},
+		{"STEAM_1:0:11101", gaben, false},
+		{"steam_0:0:11101", gaben, false},
+		{"https://steamcommunity.com/profiles/76561197960287930", gaben, false},
+		{"https://steamcommunity.com/profiles/76561197960287930/inventory/", gaben, false},
+		{"steamcommunity.com/profiles/76561197960287930", gaben, false},
+		{"https://steamcommunity.com/id/gabelogannewell", 0, true},
+		{"https://steamcommunity.com/", 0, true},
+		{"https://example.com/profiles/76561197960287930", 0, true},
+		{"https://evilsteamcommunity.com/profiles/76561197960287930", 0, true},
+		{"evilsteamcommunity.com/profiles/76561197960287930", 0, true},
+		{"https://steamcommunity.com.evil.com/profiles/76561197960287930", 0, true},
+		{"https://www.steamcommunity.com/profiles/76561197960287930", gaben, false},
+		{"76561197960265728", 0, true},
+		{"76561202255233024", 0, true},
+		{"7656119796028793", 0, true},
+		{"[U:1:0]", 0, true},
+		{"STEAM_0:0:0", 0, true},
+		{"STEAM_0:2:11101", 0, true},
+		{"gabelogannewell", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if tt.wantErr {
+				if err != ErrInvalid {
+					t.Errorf("Parse(%q) = %v, %v, want ErrInvalid", tt.input, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Parse(%q) = %v, %v, want %v", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	if got := gaben.AccountID(); got != 22202 {
+		t.Errorf("AccountID() = %d, want 22202", got)
+	}
+	if got := gaben.SteamID3(); got != "[U:1:22202]" {
+		t.Errorf("SteamID3() = %q", got)
+	}
+	if got := gaben.Steam2(); got != "STEAM_0:0:11101" {
+		t.Errorf("Steam2() = %q", got)
+	}
+	if got := FromAccountID(22203).Steam2(); got != "STEAM_0:1:11101" {
+		t.Errorf("Steam2() odd account = %q", got)
+	}
+
+	// 各格式輸出後應能解析回相同的 SteamID
+	for _, s := range []string{gaben.String(), gaben.SteamID3(), gaben.Steam2()} {
+		if got, err := Parse(s); err != nil || got != gaben {
+			t.Errorf("Parse(%q) = %v, %v, want %v", s, got, err, gaben)
+		}
+	}
+}
+
+func TestParseTradeURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		want      SteamID
+		wantToken string
+		wantErr   bool
+	}{
+		{"valid", "https://steamcommunity.com/tradeoffer/new/?partner=22202&token=AbC-12_x", gaben, "AbC-12_x", false},
+		{"without trailing slash", "https://steamcommunity.com/tradeoffer/new?partner=22202&token=AbCd1234", gaben, "AbCd1234", false},
+		{"http scheme", "http://steamcommunity.com/tradeoffer/new/?partner=22202&token=AbCd1234", 0, "", true},
+		{"other host", "https://steamcommunity.co/tradeoffer/new/?partner=22202&token=AbCd1234", 0, "", true},
+		{"other path", "https://steamcommunity.com/tradeoffers/?partner=22202&token=AbCd1234", 0, "", true},
+		{"missing partner", "https://steamcommunity.com/tradeoffer/new/?token=AbCd1234", 0, "", true},
+		{"zero partner", "https://steamcommunity.com/tradeoffer/new/?partner=0&token=AbCd1234", 0, "", true},
+		{"partner overflow", "https://steamcommunity.com/tradeoffer/new/?partner=4294967296&token=AbCd1234", 0, "", true},
+		{"missing token", "https://steamcommunity.com/tradeoffer/new/?partner=22202", 0, "", true},
+		{"short token", "https://steamcommunity.com/tradeoffer/new/?partner=22202&token=AbCd123", 0, "", true},
+		{"invalid token character", "https://steamcommunity.com/tradeoffer/new/?partner=22202&token=AbCd123!", 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, token, err := ParseTradeURL(tt.raw)
+			if tt.wantErr {
+				if err != ErrInvalidTradeURL {
+					t.Errorf("ParseTradeURL() = %v, %q, %v, want ErrInvalidTradeURL", got, token, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want || token != tt.wantToken {
+				t.Errorf("ParseTradeURL() = %v, %q, %v, want %v, %q", got, token, err, tt.want, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ISteamUser/ResolveVanityURL/v0001/" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("vanityurl") == "gabelogannewell" {
+			w.Write([]byte(`{"response":{"steamid":"76561197960287930","success":1}}`))
+			return
+		}
+		w.Write([]byte(`{"response":{"success":42,"message":"No match"}}`))
+	}))
+	defer server.Close()
+
+	previous := steam.APIURL
+	steam.APIURL = server.URL
+	defer func() { steam.APIURL = previous }()
+
+	tests := []struct {
+		input   string
+		want    SteamID
+		wantErr error
+	}{
+		{"https://steamcommunity.com/id/gabelogannewell/", gaben, nil},
+		{"https://steamcommunity.com/id/nobody", 0, ErrVanityNotFound},
+		{"https://steamcommunity.com/id/a", 0, ErrInvalid},
+		{"STEAM_0:0:11101", gaben, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Resolve(context.Background(), tt.input)
+			if err != tt.wantErr || got != tt.want {
+				t.Errorf("Resolve(%q) = %v, %v, want %v, %v", tt.input, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
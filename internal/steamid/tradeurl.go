@@ -0,0 +1,36 @@
+package steamid
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+var ErrInvalidTradeURL = errors.New("invalid trade url")
+
+var tradeTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{8}$`)
+
+// ParseTradeURL 解析 https://steamcommunity.com/tradeoffer/new/?partner=n&token=t 格式的交易網址，
+// 回傳對應的 SteamID 與 token
+func ParseTradeURL(raw string) (SteamID, string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host != "steamcommunity.com" {
+		return 0, "", ErrInvalidTradeURL
+	}
+	if u.Path != "/tradeoffer/new/" && u.Path != "/tradeoffer/new" {
+		return 0, "", ErrInvalidTradeURL
+	}
+
+	query := u.Query()
+	partner, err := strconv.ParseUint(query.Get("partner"), 10, 32)
+	if err != nil || partner == 0 {
+		return 0, "", ErrInvalidTradeURL
+	}
+	token := query.Get("token")
+	if !tradeTokenPattern.MatchString(token) {
+		return 0, "", ErrInvalidTradeURL
+	}
+
+	return FromAccountID(uint32(partner)), token, nil
+}
@@ -0,0 +1,87 @@
+package stock
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/orders"
+	"yt-api/internal/utils"
+)
+
+// ReleaseStale 依訂單狀態處理預留：過期、取消或審核未通過的釋放，已付款但沒收到轉換的補上轉換。
+// 找不到訂單的預留可能是剛建立尚未寫入，連續兩次都找不到 (missing 中已有) 才釋放
+func ReleaseStale(ctx context.Context, missing map[string]bool) (int, error) {
+	products, err := catalog.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	seen := make(map[string]bool)
+	for _, product := range products {
+		reservations, err := Reservations(ctx, product)
+		if err != nil {
+			return released, err
+		}
+		for orderID, count := range reservations {
+			order, err := orders.Get(ctx, orderID)
+			if err == orders.ErrOrderNotFound {
+				seen[orderID] = true
+				if !missing[orderID] {
+					continue
+				}
+			} else if err != nil {
+				return released, err
+			}
+
+			switch {
+			case order == nil, order.Status == orders.StatusExpired, order.Status == orders.StatusCancelled, order.Status == orders.StatusRejected:
+				if _, err := Release(ctx, product, orderID); err != nil {
+					return released, err
+				}
+				released += count
+			case order.Paid():
+				if _, err := Convert(ctx, product, orderID, count); err != nil {
+					return released, err
+				}
+			}
+		}
+	}
+
+	for orderID := range missing {
+		delete(missing, orderID)
+	}
+	for orderID := range seen {
+		missing[orderID] = true
+	}
+	return released, nil
+}
+
+// OnReleased 在釋放預留後呼叫，用來讓狀態快取失效
+var OnReleased func()
+
+// StartReleaser 定期釋放過期訂單的預留，間隔由 STOCK_RELEASE_INTERVAL 設定
+func StartReleaser() {
+	interval := utils.GetEnvDuration("STOCK_RELEASE_INTERVAL", time.Minute)
+
+	go func() {
+		missing := make(map[string]bool)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			n, err := ReleaseStale(ctx, missing)
+			cancel()
+			if err != nil {
+				log.Println("Error releasing stock reservations:", err)
+			} else if n > 0 {
+				log.Printf("Released %d reserved stock from expired orders", n)
+			}
+			if n > 0 && OnReleased != nil {
+				OnReleased()
+			}
+		}
+	}()
+}
@@ -0,0 +1,183 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 每項商品在 StockKey 之外使用四個 key：
+// <StockKey>_RESERVED 為預留中的總數，<StockKey>_RESERVATIONS 為訂單編號對應的預留數量，
+// <StockKey>_SOLD 為已轉為售出的訂單編號，避免重複的付款通知重複扣庫存，
+// <StockKey>_BOTS 為機器人回報的庫存加總。StockKey 由販售與管理員維護，機器人不會覆寫
+const (
+	reservedSuffix     = "_RESERVED"
+	reservationsSuffix = "_RESERVATIONS"
+	soldSuffix         = "_SOLD"
+	botsSuffix         = "_BOTS"
+)
+
+var ErrOutOfStock = errors.New("out of stock")
+
+// reserveScript 在 physical - reserved 足夠時預留庫存，有機器人回報時 physical 不超過機器人的加總，
+// 同一張訂單重複預留不會重複計算
+var reserveScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[3], ARGV[1]) == 1 then
+	return 1
+end
+local physical = tonumber(redis.call('GET', KEYS[1]) or '0')
+local bots = redis.call('GET', KEYS[4])
+if bots then
+	physical = math.min(physical, tonumber(bots))
+end
+local reserved = tonumber(redis.call('GET', KEYS[2]) or '0')
+local count = tonumber(ARGV[2])
+if physical - reserved < count then
+	return 0
+end
+redis.call('INCRBY', KEYS[2], count)
+redis.call('HSET', KEYS[3], ARGV[1], count)
+return 1
+`)
+
+// releaseScript 釋放訂單的預留，回傳釋放的數量
+var releaseScript = redis.NewScript(`
+local count = redis.call('HGET', KEYS[2], ARGV[1])
+if not count then
+	return 0
+end
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('DECRBY', KEYS[1], count)
+return tonumber(count)
+`)
+
+// convertScript 將訂單的預留轉為售出並從實體庫存扣除，沒有預留時 (例如過期後才付款) 以 ARGV[2] 扣除
+var convertScript = redis.NewScript(`
+if redis.call('SADD', KEYS[4], ARGV[1]) == 0 then
+	return 0
+end
+local count = redis.call('HGET', KEYS[3], ARGV[1])
+if count then
+	redis.call('HDEL', KEYS[3], ARGV[1])
+	redis.call('DECRBY', KEYS[2], count)
+else
+	count = ARGV[2]
+end
+redis.call('DECRBY', KEYS[1], count)
+return tonumber(count)
+`)
+
+// restockScript 將已售出的訂單移出售出紀錄並以 ARGV[2] 加回實體庫存，重複呼叫時不會重複加回
+var restockScript = redis.NewScript(`
+if redis.call('SREM', KEYS[2], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('INCRBY', KEYS[1], ARGV[2])
+return tonumber(ARGV[2])
+`)
+
+// Level 為商品的庫存狀態，Available = Physical - Reserved，有機器人回報時 Physical 以 Bots 為上限
+type Level struct {
+	Physical  int  `json:"physical"`
+	Reserved  int  `json:"reserved"`
+	Bots      *int `json:"bots,omitempty"`
+	Available int  `json:"available"`
+}
+
+// BotsKey 回傳存放機器人回報庫存加總的 key
+func BotsKey(product catalog.Product) string {
+	return product.StockKey + botsSuffix
+}
+
+// Reserve 為訂單預留庫存，可售數量不足時回傳 ErrOutOfStock
+func Reserve(ctx context.Context, product catalog.Product, orderID string, count int) error {
+	ok, err := reserveScript.Run(ctx, model.RedisClient,
+		[]string{product.StockKey, product.StockKey + reservedSuffix, product.StockKey + reservationsSuffix, BotsKey(product)},
+		orderID, count).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrOutOfStock
+	}
+	return nil
+}
+
+// Release 在訂單過期或取消時釋放預留，回傳釋放的數量
+func Release(ctx context.Context, product catalog.Product, orderID string) (int, error) {
+	return releaseScript.Run(ctx, model.RedisClient,
+		[]string{product.StockKey + reservedSuffix, product.StockKey + reservationsSuffix},
+		orderID).Int()
+}
+
+// Convert 在訂單付款後將預留轉為售出，回傳扣除的數量，重複呼叫時回傳 0
+func Convert(ctx context.Context, product catalog.Product, orderID string, count int) (int, error) {
+	return convertScript.Run(ctx, model.RedisClient,
+		[]string{product.StockKey, product.StockKey + reservedSuffix, product.StockKey + reservationsSuffix, product.StockKey + soldSuffix},
+		orderID, count).Int()
+}
+
+// Restock 在已付款的訂單被拒絕出貨時將售出的數量加回實體庫存，回傳加回的數量，未售出或重複呼叫時回傳 0
+func Restock(ctx context.Context, product catalog.Product, orderID string, count int) (int, error) {
+	return restockScript.Run(ctx, model.RedisClient,
+		[]string{product.StockKey, product.StockKey + soldSuffix},
+		orderID, count).Int()
+}
+
+// Get 回傳商品的實體庫存、預留數量與可售數量
+func Get(ctx context.Context, product catalog.Product) (Level, error) {
+	values, err := model.RedisClient.MGet(ctx, product.StockKey, product.StockKey+reservedSuffix, BotsKey(product)).Result()
+	if err != nil {
+		return Level{}, err
+	}
+	var level Level
+	if s, ok := values[0].(string); ok {
+		if level.Physical, err = strconv.Atoi(s); err != nil {
+			return Level{}, err
+		}
+	}
+	if s, ok := values[1].(string); ok {
+		if level.Reserved, err = strconv.Atoi(s); err != nil {
+			return Level{}, err
+		}
+	}
+	if s, ok := values[2].(string); ok {
+		bots, err := strconv.Atoi(s)
+		if err != nil {
+			return Level{}, err
+		}
+		level.Bots = &bots
+	}
+	level.Available = available(level.Physical, level.Reserved, level.Bots)
+	return level, nil
+}
+
+// available 回傳可售數量，計算方式與 reserveScript 相同
+func available(physical, reserved int, bots *int) int {
+	if bots != nil && *bots < physical {
+		physical = *bots
+	}
+	if physical < reserved {
+		return 0
+	}
+	return physical - reserved
+}
+
+// Reservations 回傳商品目前預留中的訂單編號與數量
+func Reservations(ctx context.Context, product catalog.Product) (map[string]int, error) {
+	values, err := model.RedisClient.HGetAll(ctx, product.StockKey+reservationsSuffix).Result()
+	if err != nil {
+		return nil, err
+	}
+	reservations := make(map[string]int, len(values))
+	for orderID, value := range values {
+		count, _ := strconv.Atoi(value)
+		reservations[orderID] = count
+	}
+	return reservations, nil
+}
@@ -0,0 +1,162 @@
+package stock
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"yt-api/internal/catalog"
+	"yt-api/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestAvailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		physical int
+		reserved int
+		bots     *int
+		want     int
+	}{
+		{"no reservations", 10, 0, nil, 10},
+		{"reserved subtracted", 10, 4, nil, 6},
+		{"fully reserved", 10, 10, nil, 0},
+		{"over reserved clamps to zero", 5, 8, nil, 0},
+		{"bots below physical cap it", 10, 3, intPtr(6), 3},
+		{"bots above physical ignored", 10, 3, intPtr(50), 7},
+		{"bots report zero", 10, 0, intPtr(0), 0},
+		{"bots below reserved clamps to zero", 10, 5, intPtr(2), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := available(tt.physical, tt.reserved, tt.bots); got != tt.want {
+				t.Errorf("available(%d, %d, %v) = %d, want %d", tt.physical, tt.reserved, tt.bots, got, tt.want)
+			}
+		})
+	}
+}
+
+// useTestRedis 連接 REDIS_TEST_URL 並回傳使用獨立 key 的商品，未設定時略過測試
+func useTestRedis(t *testing.T, physical int, bots *int) catalog.Product {
+	t.Helper()
+	redisURL := os.Getenv("REDIS_TEST_URL")
+	if redisURL == "" {
+		t.Skip("REDIS_TEST_URL not set")
+	}
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := model.RedisClient
+	model.RedisClient = redis.NewClient(opt)
+
+	product := catalog.Product{StockKey: "TEST_STOCK_" + strconv.FormatInt(time.Now().UnixNano(), 10)}
+	ctx := context.Background()
+	if err := model.RedisClient.Set(ctx, product.StockKey, physical, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if bots != nil {
+		if err := model.RedisClient.Set(ctx, BotsKey(product), *bots, 0).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		model.RedisClient.Del(ctx, product.StockKey, product.StockKey+reservedSuffix,
+			product.StockKey+reservationsSuffix, product.StockKey+soldSuffix, BotsKey(product))
+		model.RedisClient.Close()
+		model.RedisClient = previous
+	})
+	return product
+}
+
+func checkLevel(t *testing.T, product catalog.Product, physical, reserved, available int) {
+	t.Helper()
+	level, err := Get(context.Background(), product)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level.Physical != physical || level.Reserved != reserved || level.Available != available {
+		t.Fatalf("Get() = %+v, want physical %d reserved %d available %d", level, physical, reserved, available)
+	}
+}
+
+func TestReserveReleaseConvert(t *testing.T) {
+	product := useTestRedis(t, 10, nil)
+	ctx := context.Background()
+
+	if err := Reserve(ctx, product, "A", 6); err != nil {
+		t.Fatalf("Reserve(A) error = %v", err)
+	}
+	// 同一張訂單重複預留不會重複計算
+	if err := Reserve(ctx, product, "A", 6); err != nil {
+		t.Fatalf("Reserve(A) again error = %v", err)
+	}
+	checkLevel(t, product, 10, 6, 4)
+
+	if err := Reserve(ctx, product, "B", 5); err != ErrOutOfStock {
+		t.Fatalf("Reserve(B) error = %v, want ErrOutOfStock", err)
+	}
+	if err := Reserve(ctx, product, "B", 4); err != nil {
+		t.Fatalf("Reserve(B) error = %v", err)
+	}
+	checkLevel(t, product, 10, 10, 0)
+
+	if n, err := Release(ctx, product, "B"); err != nil || n != 4 {
+		t.Fatalf("Release(B) = %d, %v, want 4", n, err)
+	}
+	if n, err := Release(ctx, product, "B"); err != nil || n != 0 {
+		t.Fatalf("Release(B) again = %d, %v, want 0", n, err)
+	}
+	checkLevel(t, product, 10, 6, 4)
+
+	if n, err := Convert(ctx, product, "A", 6); err != nil || n != 6 {
+		t.Fatalf("Convert(A) = %d, %v, want 6", n, err)
+	}
+	if n, err := Convert(ctx, product, "A", 6); err != nil || n != 0 {
+		t.Fatalf("Convert(A) again = %d, %v, want 0", n, err)
+	}
+	checkLevel(t, product, 4, 0, 4)
+
+	// 預留已釋放後才付款，以訂單數量扣除
+	if n, err := Convert(ctx, product, "B", 4); err != nil || n != 4 {
+		t.Fatalf("Convert(B) after release = %d, %v, want 4", n, err)
+	}
+	checkLevel(t, product, 0, 0, 0)
+
+	// 已售出的訂單被拒絕時加回實體庫存，重複呼叫不會重複加回
+	if n, err := Restock(ctx, product, "A", 6); err != nil || n != 6 {
+		t.Fatalf("Restock(A) = %d, %v, want 6", n, err)
+	}
+	if n, err := Restock(ctx, product, "A", 6); err != nil || n != 0 {
+		t.Fatalf("Restock(A) again = %d, %v, want 0", n, err)
+	}
+	if n, err := Restock(ctx, product, "C", 6); err != nil || n != 0 {
+		t.Fatalf("Restock(C) not sold = %d, %v, want 0", n, err)
+	}
+	checkLevel(t, product, 6, 0, 6)
+
+	reservations, err := Reservations(ctx, product)
+	if err != nil || len(reservations) != 0 {
+		t.Fatalf("Reservations() = %v, %v, want empty", reservations, err)
+	}
+}
+
+func TestReserveBotsCap(t *testing.T) {
+	product := useTestRedis(t, 10, intPtr(3))
+	ctx := context.Background()
+
+	if err := Reserve(ctx, product, "A", 4); err != ErrOutOfStock {
+		t.Fatalf("Reserve(A) error = %v, want ErrOutOfStock", err)
+	}
+	if err := Reserve(ctx, product, "A", 3); err != nil {
+		t.Fatalf("Reserve(A) error = %v", err)
+	}
+	checkLevel(t, product, 10, 3, 0)
+}
@@ -6,5 +6,29 @@ type BotStatus struct {
 	Orders       int
 	Updated      int64
 	MarketPrice  int
+	MarketPrices []MarketPrice
 	Transcations int
+	Paused       bool
+	Products     map[string]ProductStatus
+}
+
+// ProductStatus 表示單一商品的價格、庫存與統計
+type ProductStatus struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+	Stock int    `json:"stock"`
+	// PhysicalStock 為機器人實際持有的數量，Stock = PhysicalStock - Reserved
+	PhysicalStock int `json:"physicalStock"`
+	Reserved      int `json:"reserved"`
+	Orders        int `json:"orders"`
+	MarketPrice   int `json:"marketPrice"`
+	Transactions  int `json:"transactions"`
+}
+
+// MarketPrice 表示單一市場來源的最低售價
+type MarketPrice struct {
+	ItemNameID int    `json:"itemNameId"`
+	Currency   string `json:"currency"`
+	Price      int    `json:"price"`
 }
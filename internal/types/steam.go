@@ -52,28 +52,44 @@ type Inventory struct {
 
 type ProfileResponse struct {
 	Response struct {
-		Players []struct {
-			SteamID             string `json:"steamid"`
-			CommunityVisibility int    `json:"communityvisibilitystate"`
-			ProfileState        int    `json:"profilestate"`
-			PersonaName         string `json:"personaname"`
-			ProfileURL          string `json:"profileurl"`
-			Avatar              string `json:"avatar"`
-			AvatarMedium        string `json:"avatarmedium"`
-			AvatarFull          string `json:"avatarfull"`
-			AvatarHash          string `json:"avatarhash"`
-			PersonaState        int    `json:"personastate"`
-			RealName            string `json:"realname"`
-			PrimaryClanID       string `json:"primaryclanid"`
-			TimeCreated         int    `json:"timecreated"`
-			PersonaStateFlags   int    `json:"personastateflags"`
-			LoccountryCode      string `json:"loccountrycode"`
-			LocStateCode        string `json:"locstatecode"`
-			LoccityID           int    `json:"loccityid"`
-		} `json:"players"`
+		Players []Player `json:"players"`
 	} `json:"response"`
 }
 
+type Player struct {
+	SteamID             string `json:"steamid"`
+	CommunityVisibility int    `json:"communityvisibilitystate"`
+	ProfileState        int    `json:"profilestate"`
+	PersonaName         string `json:"personaname"`
+	ProfileURL          string `json:"profileurl"`
+	Avatar              string `json:"avatar"`
+	AvatarMedium        string `json:"avatarmedium"`
+	AvatarFull          string `json:"avatarfull"`
+	AvatarHash          string `json:"avatarhash"`
+	PersonaState        int    `json:"personastate"`
+	RealName            string `json:"realname"`
+	PrimaryClanID       string `json:"primaryclanid"`
+	TimeCreated         int    `json:"timecreated"`
+	PersonaStateFlags   int    `json:"personastateflags"`
+	LoccountryCode      string `json:"loccountrycode"`
+	LocStateCode        string `json:"locstatecode"`
+	LoccityID           int    `json:"loccityid"`
+}
+
+type PlayerBansResponse struct {
+	Players []PlayerBans `json:"players"`
+}
+
+type PlayerBans struct {
+	SteamID          string `json:"SteamId"`
+	CommunityBanned  bool   `json:"CommunityBanned"`
+	VACBanned        bool   `json:"VACBanned"`
+	NumberOfVACBans  int    `json:"NumberOfVACBans"`
+	DaysSinceLastBan int    `json:"DaysSinceLastBan"`
+	NumberOfGameBans int    `json:"NumberOfGameBans"`
+	EconomyBan       string `json:"EconomyBan"`
+}
+
 type MarketItem struct {
 	Success           int    `json:"success"`
 	SellOrderTable    string `json:"sell_order_table"`
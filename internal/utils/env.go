@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnv 讀取環境變數，未設定時回傳預設值
+func GetEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// GetEnvInt 讀取整數環境變數，未設定或格式錯誤時回傳預設值
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvDuration 讀取時間長度環境變數 (例如 "5m")，未設定或格式錯誤時回傳預設值
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Taipei 為訂單編號 (Data_id) 使用的時區
+var Taipei = time.FixedZone("Asia/Taipei", 8*60*60)